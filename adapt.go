@@ -0,0 +1,191 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Self-adaptive GA (the 'adaptive' GA parameter) : Rather than fixed percentages, the variation operators
+// (and the mutation radius for the overlays) are chosen by adaptive pursuit.  Each generation, an operator's
+// reward is the fraction of its offspring that beat their parent, its quality follows the rewards, and the
+// probabilities are pulled towards the best quality one (without any dropping below p_min).
+// See : Thierens, "An Adaptive Pursuit Strategy for Allocating Operator Probabilities" (GECCO 2005)
+
+type GAOperator int
+
+const (
+	Op_None          GAOperator = iota // Not made by an operator (the initial population, or the elite copy)
+	Op_Copy                            // Copied from a parent unchanged
+	Op_Crossover                       // From two parents
+	Op_DiffOverlay                     // A start patch overlaid around one of the parent's errors
+	Op_TargetOverlay                   // A start patch overlaid somewhere in the target
+)
+
+func (op GAOperator) String() string {
+	return [...]string{"none", "copy", "crossover", "diff-overlay", "target-overlay"}[op]
+}
+
+// The operators that adaptive pursuit chooses between (copies stay at the fixed percentage)
+var ga_adaptive_operators = []GAOperator{Op_Crossover, Op_DiffOverlay, Op_TargetOverlay}
+
+type adaptive_pursuit struct {
+	p, q               []float64 // Probability and quality of each arm
+	p_min, alpha, beta float64
+}
+
+func new_adaptive_pursuit(p []float64) *adaptive_pursuit {
+	ap := &adaptive_pursuit{
+		p:     append([]float64(nil), p...),
+		q:     make([]float64, len(p)),
+		p_min: 0.5 / float64(len(p)),
+		alpha: 0.1,
+		beta:  0.1,
+	}
+	for i := range ap.q {
+		ap.q[i] = 0.5 // No idea yet
+	}
+	return ap
+}
+
+// Choose an arm at random, according to the probabilities
+func (ap *adaptive_pursuit) Choose(random *rand.Rand) int {
+	r := random.Float64()
+	for arm, p := range ap.p {
+		if r < p {
+			return arm
+		}
+		r -= p
+	}
+	return len(ap.p) - 1
+}
+
+// Update with the rewards (in 0..1) of the arms that were used (used[arm]>0)
+func (ap *adaptive_pursuit) Update(rewards []float64, used []int) {
+	for arm := range ap.q {
+		if used[arm] > 0 {
+			ap.q[arm] += ap.alpha * (rewards[arm] - ap.q[arm])
+		}
+	}
+	best, tied := 0, false
+	for arm := 1; arm < len(ap.q); arm++ {
+		if ap.q[arm] > ap.q[best] {
+			best, tied = arm, false
+		} else if ap.q[arm] == ap.q[best] {
+			tied = true
+		}
+	}
+	if tied { // Nothing to choose between them (e.g. nothing has improved on its parent yet)
+		return
+	}
+	p_max := 1 - float64(len(ap.p)-1)*ap.p_min
+	for arm := range ap.p {
+		if arm == best {
+			ap.p[arm] += ap.beta * (p_max - ap.p[arm])
+		} else {
+			ap.p[arm] += ap.beta * (ap.p_min - ap.p[arm])
+		}
+	}
+}
+
+type GAAdapter struct {
+	operators *adaptive_pursuit // Over ga_adaptive_operators
+	radii     *adaptive_pursuit // Over radius 1..len(radii.p)
+
+	made, better map[GAOperator]int // Over the whole run, for the report
+}
+
+// Start from the fixed percentages in params, and any radius up to twice params.MutationRadius
+func NewGAAdapter(params GAParams) *GAAdapter {
+	overlay := float64(params.MutationPct)
+	start := []float64{float64(params.CrossoverPct), overlay * 0.8, overlay * 0.2} // As GenerationAfter splits them
+	total := start[0] + start[1] + start[2]
+	for i := range start {
+		if total > 0 {
+			start[i] /= total
+		} else {
+			start[i] = 1 / float64(len(start))
+		}
+	}
+	n_radii := 2 * params.MutationRadius
+	if n_radii < 2 {
+		n_radii = 2
+	}
+	radii := make([]float64, n_radii)
+	for i := range radii {
+		radii[i] = 1 / float64(n_radii)
+	}
+	return &GAAdapter{
+		operators: new_adaptive_pursuit(start),
+		radii:     new_adaptive_pursuit(radii),
+		made:      make(map[GAOperator]int),
+		better:    make(map[GAOperator]int),
+	}
+}
+
+func (a *GAAdapter) ChooseOperator(random *rand.Rand) GAOperator {
+	return ga_adaptive_operators[a.operators.Choose(random)]
+}
+
+func (a *GAAdapter) ChooseRadius(random *rand.Rand) int {
+	return a.radii.Choose(random) + 1
+}
+
+// Credit the operators (and radii) with how the individuals they made compare to their parents.
+// Call this once the generation's fitnesses are in
+func (a *GAAdapter) Credit(individuals []*Individual) {
+	n := len(ga_adaptive_operators)
+	op_used, op_rewards := make([]int, n), make([]float64, n)
+	radius_used, radius_rewards := make([]int, len(a.radii.p)), make([]float64, len(a.radii.p))
+	for _, individual := range individuals {
+		if individual.op == Op_None || individual.op == Op_Copy {
+			continue
+		}
+		arm := 0
+		for i, op := range ga_adaptive_operators {
+			if op == individual.op {
+				arm = i
+			}
+		}
+		improved := 0.0
+		if individual.fitness > individual.parent_fitness {
+			improved = 1
+			a.better[individual.op]++
+		}
+		a.made[individual.op]++
+		op_used[arm]++
+		op_rewards[arm] += improved
+		if individual.radius > 0 {
+			radius_used[individual.radius-1]++
+			radius_rewards[individual.radius-1] += improved
+		}
+	}
+	for arm := range op_rewards {
+		if op_used[arm] > 0 {
+			op_rewards[arm] /= float64(op_used[arm])
+		}
+	}
+	for arm := range radius_rewards {
+		if radius_used[arm] > 0 {
+			radius_rewards[arm] /= float64(radius_used[arm])
+		}
+	}
+	a.operators.Update(op_rewards, op_used)
+	a.radii.Update(radius_rewards, radius_used)
+}
+
+// The final mix, and how often each operator beat the parent over the run
+func (a *GAAdapter) String() string {
+	parts := []string{}
+	for i, op := range ga_adaptive_operators {
+		parts = append(parts, fmt.Sprintf("%s %.0f%% (%d/%d better)", op, 100*a.operators.p[i], a.better[op], a.made[op]))
+	}
+	radii := []string{}
+	for i, p := range a.radii.p {
+		radii = append(radii, fmt.Sprintf("%d:%.0f%%", i+1, 100*p))
+	}
+	return strings.Join(parts, ", ") + " ; radius " + strings.Join(radii, " ")
+}
@@ -0,0 +1,200 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Simulated annealing : A single start board, changed by the GA's moves (a start patch from the transition
+// table overlaid around a wrong end cell, or MutateMask flips around the wrong end cells), with worse
+// boards accepted with probability exp(-extra mismatches/T).  Moves are evaluated incrementally (see IncrementalBoard).
+
+type AnnealCooling int
+
+const (
+	Anneal_Geometric AnnealCooling = iota // T falls by the same factor every move
+	Anneal_Linear                         // T falls by the same amount every move
+)
+
+type AnnealRestart int
+
+const (
+	Anneal_FromBest AnnealRestart = iota // Reheat, starting from the best board so far
+	Anneal_FromEnd                       // Start again from the end board (as the GA does)
+)
+
+type AnnealSchedule struct {
+	t_start, t_end float64 // Temperature (in mismatched cells) at the start and end of each run
+	cooling        AnnealCooling
+	moves          int // Moves in each run
+	stall          int // Moves without a new best before a run is cut short (0 = never)
+	restarts       int // Runs after the first one
+	restart        AnnealRestart
+
+	overlay_pct       int // Moves that overlay a start patch (the rest are MutateMask flips)
+	mutation_loop_pct int // As for the GA
+	report_every      int // Moves between progress lines (0 = none)
+}
+
+// What Solver_Anneal uses (-type=anneal on the run command)
+var anneal_schedule = AnnealSchedule{
+	t_start: 2.0, t_end: 0.05, cooling: Anneal_Geometric,
+	moves: 50000, stall: 10000, restarts: 4, restart: Anneal_FromBest,
+	overlay_pct: 70, mutation_loop_pct: 50, report_every: 10000,
+}
+
+func (s AnnealSchedule) Temperature(move int) float64 {
+	f := float64(move) / float64(s.moves)
+	if s.cooling == Anneal_Linear {
+		return s.t_start + (s.t_end-s.t_start)*f
+	}
+	return s.t_start * math.Pow(s.t_end/s.t_start, f)
+}
+
+// Mismatch vs the end board as the annealing goes on
+type AnnealPoint struct {
+	moves          int
+	elapsed        time.Duration
+	temperature    float64
+	mismatch, best int
+}
+
+type Annealer struct {
+	*IncrementalBoard
+	schedule AnnealSchedule
+	radius   int
+
+	tc     *TransitionCollectionList // nil if there are no stats (so only flips are used)
+	prior  *ProbabilityMap           // Used for overlays where the end patch isn't in tc
+	random *rand.Rand
+
+	moves int
+	trace []AnnealPoint
+}
+
+func NewAnnealer(target *Board_BoolPacked, steps int, rule *Rule, schedule AnnealSchedule, random *rand.Rand) *Annealer {
+	return &Annealer{
+		IncrementalBoard: NewIncrementalBoard(target, steps, rule),
+		schedule:         schedule,
+		radius:           steps,
+		random:           random,
+	}
+}
+
+// Move makes a random change to the start board, and keeps it if the Metropolis test at temperature t says so
+func (a *Annealer) Move(t float64) {
+	a.moves++
+	chase := a.Chase()
+
+	if a.random.Intn(100) < a.schedule.overlay_pct {
+		x, y := chase.RandomBitPosition(a.random)
+		if x < 0 || y < 0 {
+			x, y = a.target.RandomBitPosition(a.random) // Nothing to chase, so somewhere in the target for a change
+		}
+		if x >= 0 && y >= 0 {
+			x = CoordWithinRadius(x, a.start.w, a.radius/2+1, a.random)
+			y = CoordWithinRadius(y, a.start.h, a.radius/2+1, a.random)
+			p := Patch(-1)
+			if a.tc != nil {
+				p = a.tc.GetRandomEntry_OrientationCompensated(a.target.MakePatch(x, y), a.random)
+			}
+			if p >= 0 {
+				a.trial.OverlayPatch(x, y, p)
+			} else if a.prior != nil {
+				a.trial.SampleFrom(a.prior, x, y, 1, a.random)
+			} else {
+				a.trial.MutateMask(chase, a.schedule.mutation_loop_pct, a.radius, a.random)
+			}
+		}
+	} else {
+		a.trial.MutateMask(chase, a.schedule.mutation_loop_pct, a.radius, a.random)
+	}
+
+	c, ok := a.evaluate()
+	if !ok {
+		return
+	}
+	if c.delta > 0 && (t <= 0 || a.random.Float64() >= math.Exp(-float64(c.delta)/t)) {
+		a.revert(c)
+		return
+	}
+	a.commit(c)
+}
+
+// Run anneals (with restarts) until the schedule is done, a best board with only the floor of mismatches
+// is found, or the deadline (if not zero) passes.  Returns the best start board found
+func (a *Annealer) Run(deadline time.Time) *Board_BoolPacked {
+	t0 := time.Now()
+	best := NewBoard_BoolPacked(a.start.w, a.start.h)
+	best.CopyFrom(a.start)
+	best_mismatch := a.mismatch
+	a.trace = append(a.trace, AnnealPoint{0, 0, a.schedule.t_start, a.mismatch, best_mismatch})
+
+	for run := 0; run <= a.schedule.restarts; run++ {
+		if run > 0 {
+			if a.schedule.restart == Anneal_FromEnd {
+				a.Reset(a.target)
+			} else {
+				a.Reset(best)
+			}
+		}
+		last_best := 0
+		for i := 0; i < a.schedule.moves; i++ {
+			t := a.schedule.Temperature(i)
+			a.Move(t)
+			if a.mismatch < best_mismatch {
+				best.CopyFrom(a.start)
+				best_mismatch, last_best = a.mismatch, i
+				a.trace = append(a.trace, AnnealPoint{a.moves, time.Since(t0), t, a.mismatch, best_mismatch})
+			}
+			if a.schedule.report_every > 0 && a.moves%a.schedule.report_every == 0 {
+				fmt.Printf("%7d moves (run %d, %5.1fs) T=%5.3f : Mismatch vs end = %3d (best %3d)\n",
+					a.moves, run, time.Since(t0).Seconds(), t, a.mismatch, best_mismatch)
+			}
+			if best_mismatch <= a.floor {
+				return best
+			}
+			if a.schedule.stall > 0 && i-last_best > a.schedule.stall {
+				break
+			}
+			if a.moves%1024 == 0 && !deadline.IsZero() && time.Now().After(deadline) {
+				return best
+			}
+		}
+	}
+	return best
+}
+
+type Solver_Anneal struct{}
+
+func (Solver_Anneal) Solve(problem LifeProblem, lps *LifeProblemSet, budget time.Duration, random *rand.Rand) *IndividualResult {
+	a := NewAnnealer(problem.end, problem.steps, problem.Rule(), anneal_schedule, random)
+	if problem.steps < len(lps.transition_collection) && len(lps.transition_collection[problem.steps].pre) > 0 {
+		a.tc = &lps.transition_collection[problem.steps]
+	}
+	if problem.steps < len(lps.cell_probabilities) && lps.cell_probabilities[problem.steps] != nil {
+		a.prior = lps.cell_probabilities[problem.steps].Map(problem.end)
+	}
+
+	if orphans := problem_orphans(problem, a.known); orphans.orphans > 0 {
+		a.unfixable, a.floor = orphans.unfixable, orphans.min_errors
+	}
+
+	mismatch_from_true_end_initial := a.mismatch
+	start := a.Run(budget_deadline(budget))
+
+	fmt.Printf("problem[%d] : Best mismatch vs end over time (moves@seconds) :", problem.id)
+	for _, point := range a.trace {
+		fmt.Printf(" %d (%d@%.2fs)", point.best, point.moves, point.elapsed.Seconds())
+	}
+	fmt.Println()
+
+	result := finish_result(problem, lps, start, mismatch_from_true_end_initial)
+	result.iter = a.moves
+	return result
+}
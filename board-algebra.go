@@ -0,0 +1,185 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"math/bits"
+)
+
+// Whole-board boolean operations, done a word at a time.
+// Like CopyFrom, the receiver is the destination (and may be one of the arguments) :
+//   diff.Xor(attempt, target)
+// Boards must have the same dimensions, the destination is resized to match if necessary
+
+func (dest *Board_BoolPacked) match_shape(a *Board_BoolPacked) {
+	if len(dest.s) != len(a.s) {
+		dest.s = make([]uint64, len(a.s))
+	}
+	dest.h, dest.w, dest.stride = a.h, a.w, a.stride
+	dest.boundary = a.boundary
+}
+
+func (dest *Board_BoolPacked) And(a, b *Board_BoolPacked) *Board_BoolPacked { // OPTIMIZED FOR BoolPacked
+	dest.match_shape(a)
+	for i := range dest.s {
+		dest.s[i] = a.s[i] & b.s[i]
+	}
+	return dest
+}
+
+func (dest *Board_BoolPacked) Or(a, b *Board_BoolPacked) *Board_BoolPacked { // OPTIMIZED FOR BoolPacked
+	dest.match_shape(a)
+	for i := range dest.s {
+		dest.s[i] = a.s[i] | b.s[i]
+	}
+	return dest
+}
+
+func (dest *Board_BoolPacked) Xor(a, b *Board_BoolPacked) *Board_BoolPacked { // OPTIMIZED FOR BoolPacked
+	dest.match_shape(a)
+	for i := range dest.s {
+		dest.s[i] = a.s[i] ^ b.s[i]
+	}
+	return dest
+}
+
+// AndNot is a & ^b : i.e. the cells of a that aren't in b
+func (dest *Board_BoolPacked) AndNot(a, b *Board_BoolPacked) *Board_BoolPacked { // OPTIMIZED FOR BoolPacked
+	dest.match_shape(a)
+	for i := range dest.s {
+		dest.s[i] = a.s[i] &^ b.s[i]
+	}
+	return dest
+}
+
+// Not flips every cell (the padding stays zero)
+func (dest *Board_BoolPacked) Not(a *Board_BoolPacked) *Board_BoolPacked { // OPTIMIZED FOR BoolPacked
+	dest.match_shape(a)
+	for r := 0; r < a.h+2; r++ {
+		for k := 0; k < a.stride; k++ {
+			i := r*a.stride + k
+			if r == 0 || r == a.h+1 {
+				dest.s[i] = 0
+				continue
+			}
+			dest.s[i] = ^a.s[i] & a.cell_mask(k)
+		}
+	}
+	return dest
+}
+
+// Number of live cells
+func (f *Board_BoolPacked) Popcount() int { // OPTIMIZED FOR BoolPacked
+	r := 0
+	for _, word := range f.s {
+		r += bits.OnesCount64(word)
+	}
+	return r
+}
+
+// Move the bits of a row over by dx columns (+ve dx is towards higher x), into dst
+func shift_row(dst, src []uint64, dx int) {
+	n := len(src)
+	for k := range dst {
+		dst[k] = 0
+	}
+	if dx >= 0 {
+		ws, bs := dx>>6, uint(dx&63)
+		for k := n-1; k >= ws; k-- {
+			v := src[k-ws] << bs
+			if bs > 0 && k-ws-1 >= 0 {
+				v |= src[k-ws-1] >> (64-bs)
+			}
+			dst[k] = v
+		}
+	} else {
+		ws, bs := (-dx)>>6, uint((-dx)&63)
+		for k := 0; k+ws < n; k++ {
+			v := src[k+ws] >> bs
+			if bs > 0 && k+ws+1 < n {
+				v |= src[k+ws+1] << (64-bs)
+			}
+			dst[k] = v
+		}
+	}
+}
+
+// Shift moves every cell by (dx,dy) : Cells moved off the edge are lost, unless the board is a torus
+func (dest *Board_BoolPacked) Shift(a *Board_BoolPacked, dx, dy int) *Board_BoolPacked { // OPTIMIZED FOR BoolPacked
+	result := NewBoard_BoolPacked(a.w, a.h)
+	if a.boundary == Boundary_Torus {
+		// Wrapping is the same as also shifting the other way round the torus
+		dx, dy = ((dx % a.w) + a.w) % a.w, ((dy % a.h) + a.h) % a.h
+		for _, offset := range [][2]int{{dx, dy}, {dx-a.w, dy}, {dx, dy-a.h}, {dx-a.w, dy-a.h}} {
+			a.shift_into(result, offset[0], offset[1])
+		}
+	} else {
+		a.shift_into(result, dx, dy)
+	}
+	dest.CopyFrom(result)
+	dest.boundary = a.boundary
+	return dest
+}
+
+// Or the shifted cells of f into result (which must not be f)
+func (f *Board_BoolPacked) shift_into(result *Board_BoolPacked, dx, dy int) {
+	shifted := make([]uint64, f.stride)
+	for y := 0; y < f.h; y++ {
+		src_y := y - dy
+		if src_y < 0 || src_y >= f.h {
+			continue
+		}
+		shift_row(shifted, f.row(src_y+1), dx)
+		row := result.row(y+1)
+		for k := range row {
+			row[k] |= shifted[k] & f.cell_mask(k)
+		}
+	}
+}
+
+// Dilate sets every cell within 'radius' (in the max(|dx|,|dy|) sense) of a live cell
+func (dest *Board_BoolPacked) Dilate(a *Board_BoolPacked, radius int) *Board_BoolPacked { // OPTIMIZED FOR BoolPacked
+	// The square is separable : Spread along the rows, then along the columns
+	horizontal := NewBoard_BoolPacked(a.w, a.h)
+	horizontal.CopyFrom(a)
+	shifted := NewBoard_BoolPacked(a.w, a.h)
+	for d := 1; d <= radius; d++ {
+		horizontal.Or(horizontal, shifted.Shift(a, d, 0))
+		horizontal.Or(horizontal, shifted.Shift(a, -d, 0))
+	}
+	result := NewBoard_BoolPacked(a.w, a.h)
+	result.CopyFrom(horizontal)
+	for d := 1; d <= radius; d++ {
+		result.Or(result, shifted.Shift(horizontal, 0, d))
+		result.Or(result, shifted.Shift(horizontal, 0, -d))
+	}
+	dest.CopyFrom(result)
+	return dest
+}
+
+// BoundingBox of the live cells (inclusive), ok is false for an empty board
+func (f *Board_BoolPacked) BoundingBox() (x_min, y_min, x_max, y_max int, ok bool) { // OPTIMIZED FOR BoolPacked
+	x_min, y_min = f.w, f.h
+	x_max, y_max = -1, -1
+	for y := 0; y < f.h; y++ {
+		for k, word := range f.row(y+1) {
+			if word == 0 {
+				continue
+			}
+			if y < y_min {
+				y_min = y
+			}
+			y_max = y
+			lo := k*64 + bits.TrailingZeros64(word) - 1 // -1 for the padding bit
+			hi := k*64 + 63 - bits.LeadingZeros64(word) - 1
+			if lo < x_min {
+				x_min = lo
+			}
+			if hi > x_max {
+				x_max = hi
+			}
+		}
+	}
+	return x_min, y_min, x_max, y_max, x_max >= 0
+}
@@ -5,12 +5,16 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/csv"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 	"sort"
 )
 
@@ -26,15 +30,26 @@ import (
 // The following will work on more generalized GoL mechanics, but are 10x slower
 /****************************************************************************************/
 
+// Bring (x,y) back onto the board for Boundary_Torus (other boundaries are left alone)
+func (f *Board_BoolPacked) wrap(x, y int) (int, int) {
+	if f.boundary == Boundary_Torus {
+		x = ((x % f.w) + f.w) % f.w
+		y = ((y % f.h) + f.h) % f.h
+	}
+	return x, y
+}
+
 func (f *Board_BoolPacked) isSet_safe(x, y int) bool {
-	if x<0 || x>=board_width || y<0 || y>=board_height {
+	x, y = f.wrap(x, y)
+	if x<0 || x>=f.w || y<0 || y>=f.h {
 		return false
 	}
 	return f.isSet(x,y)
 }
 
 func (f *Board_BoolPacked) Set_safe(x, y int, b bool) {
-	if x<0 || x>=board_width || y<0 || y>=board_height {
+	x, y = f.wrap(x, y)
+	if x<0 || x>=f.w || y<0 || y>=f.h {
 		return 
 	}
 	f.Set(x,y,b)
@@ -71,6 +86,9 @@ func (f *Board_BoolPacked) LoadArray(csv_strings []string) {
 	y := 0
 
 	for _, v := range csv_strings[:] {
+		if y >= f.h {
+			break // Anything beyond w*h belongs to some other board
+		}
 		if v == "1" {
 			f.Set(x, y, true)
 			//fmt.Print("*")
@@ -84,7 +102,7 @@ func (f *Board_BoolPacked) LoadArray(csv_strings []string) {
 }
 
 // Next returns the state of the specified cell at the next time step.
-func (f *Board_BoolPacked) IterateCell(x, y int) bool {
+func (f *Board_BoolPacked) IterateCell(x, y int, rule *Rule) bool {
 	// Count the adjacent cells that are alive.
 	alive := 0
 	for i := -1; i <= 1; i++ {
@@ -94,31 +112,94 @@ func (f *Board_BoolPacked) IterateCell(x, y int) bool {
 			}
 		}
 	}
-	// Return next state according to the game rules:
+	// Return next state according to the game rules, which (for Conway) are :
 	//   exactly 3 neighbors: on,
 	//   exactly 2 neighbors: maintain current state,
 	//   otherwise: off.
-	return alive == 3 || alive == 2 && f.isSet(x, y)
+	return rule.Alive(f.isSet(x, y), alive)
 }
 
-func (f *Board_BoolPacked) Iterate_Generic(next *Board_BoolPacked) {
+func (f *Board_BoolPacked) Iterate_Generic(next *Board_BoolPacked, rule *Rule) {
 	// Update the state of the next field (next) in-place from the current field (f).
+	next.boundary = f.boundary
+	f.fill_padding() // IterateCell peeks into the padding ring
+	defer f.clear_padding()
 	for y := 0; y < f.h; y++ {
 		for x := 0; x < f.w; x++ {
-			next.Set(x, y, f.IterateCell(x, y))
+			next.Set(x, y, f.IterateCell(x, y, rule))
 		}
 	}
 }
 
+// Transformed returns a new board, which is f after the Orientation (transposing swaps w and h)
+func (f *Board_BoolPacked) Transformed(o Orientation) *Board_BoolPacked {
+	w, h := f.w, f.h
+	if o.transpose {
+		w, h = h, w
+	}
+	t := NewBoard_BoolPacked(w, h)
+	t.boundary = f.boundary
+	for y := 0; y < f.h; y++ {
+		for x := 0; x < f.w; x++ {
+			if !f.isSet(x, y) {
+				continue
+			}
+			tx, ty := x, y
+			if o.transpose {
+				tx, ty = ty, tx
+			}
+			if o.flip_ud {
+				ty = h-1-ty
+			}
+			if o.flip_lr {
+				tx = w-1-tx
+			}
+			t.Set(tx, ty, true)
+		}
+	}
+	return t
+}
+
+// Untransformed undoes Transformed
+func (f *Board_BoolPacked) Untransformed(o Orientation) *Board_BoolPacked {
+	// Flips are their own inverses, and need to be done before the transpose
+	t := f.Transformed(Orientation{flip_ud:o.flip_ud, flip_lr:o.flip_lr})
+	if o.transpose {
+		t = t.Transformed(Orientation{transpose:true})
+	}
+	return t
+}
+
+// Canonical returns the smallest (by packed encoding) of the 8 D4 versions of the board, and the
+// Orientation that produced it, so that symmetric boards can share results
+func (f *Board_BoolPacked) Canonical() (*Board_BoolPacked, Orientation) {
+	best, best_o := f.Transformed(Orientations_D4[0]), Orientations_D4[0]
+	best_data := best.toBinary()
+	for _, o := range Orientations_D4[1:] {
+		t := f.Transformed(o)
+		if data := t.toBinary(); bytes.Compare(data, best_data) < 0 {
+			best, best_o, best_data = t, o, data
+		}
+	}
+	return best, best_o
+}
+
 // String returns the game board as a string.
 func (f *Board_BoolPacked) String() string {
 	var buf bytes.Buffer
 	outer := 1
+	border := byte('0')
+	if f.boundary == Boundary_Torus {
+		border = '~'
+	}
+	if f.boundary == Boundary_Unknown {
+		border = '?'
+	}
 	for y := 0 - outer; y < f.h+outer; y++ {
 		for x := 0 - outer; x < f.w+outer; x++ {
 			b := byte('-')
 			if x < 0 || x >= f.w || y < 0 || y >= f.h {
-				b = '0'
+				b = border
 			} else { 
 				if f.isSet(x, y) {
 					b = '*'
@@ -163,6 +244,10 @@ func (f *Board_BoolPacked) toCompactString() string {
 }
 // Reads the game board as a string of 1s and 0s (compact)
 func (f *Board_BoolPacked) fromCompactString(buf string)  {
+	if len(buf) < f.w*f.h {
+		fmt.Printf("Compact string too short for %dx%d board : %d chars\n", f.w, f.h, len(buf))
+		return
+	}
 	for y,i := 0,0; y < f.h; y++ {
 		for x := 0; x < f.w; x++ {
 			f.Set(x,y, buf[i] == '1')
@@ -171,6 +256,81 @@ func (f *Board_BoolPacked) fromCompactString(buf string)  {
 	}
 }
 
+// Returns the game board packed into bits : uvarint width, uvarint height, then the cells in
+// row-major order, 8 to a byte (lowest bit first).  So a 20x20 board takes 2+50 bytes
+func (f *Board_BoolPacked) toBinary() []byte {
+	header := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutUvarint(header, uint64(f.w))
+	n += binary.PutUvarint(header[n:], uint64(f.h))
+	
+	data := make([]byte, n+(f.w*f.h+7)/8)
+	copy(data, header[:n])
+	for y,i := 0,0; y < f.h; y++ {
+		for x := 0; x < f.w; x++ {
+			if f.isSet(x, y) {
+				data[n+i/8] |= 1 << uint(i%8)
+			}
+			i++
+		}
+	}
+	return data
+}
+
+// Reads a board written by toBinary (the board is created with the dimensions in the header)
+func BoardFromBinary(data []byte) (*Board_BoolPacked, error) {
+	w, n_w := binary.Uvarint(data)
+	if n_w <= 0 {
+		return nil, fmt.Errorf("Bad width in binary board")
+	}
+	h, n_h := binary.Uvarint(data[n_w:])
+	if n_h <= 0 {
+		return nil, fmt.Errorf("Bad height in binary board")
+	}
+	n := n_w+n_h
+	if uint64(len(data)-n) != (w*h+7)/8 {
+		return nil, fmt.Errorf("Binary board has %d bytes of cells, expecting %d for %dx%d", len(data)-n, (w*h+7)/8, w, h)
+	}
+	
+	f := NewBoard_BoolPacked(int(w), int(h))
+	for y,i := 0,0; y < f.h; y++ {
+		for x := 0; x < f.w; x++ {
+			f.Set(x,y, data[n+i/8] & (1 << uint(i%8)) != 0)
+			i++
+		}
+	}
+	return f, nil
+}
+
+// Text-safe form of toBinary (this is what gets stored in the db)
+const BoardBase64Prefix = "b64:"
+
+func (f *Board_BoolPacked) toBase64() string {
+	return BoardBase64Prefix + base64.StdEncoding.EncodeToString(f.toBinary())
+}
+
+// Reads the board from either the toBase64 form or the (older) toCompactString form
+func (f *Board_BoolPacked) fromStoredString(buf string) {
+	if !strings.HasPrefix(buf, BoardBase64Prefix) {
+		f.fromCompactString(buf)
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(buf[len(BoardBase64Prefix):])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	stored, err := BoardFromBinary(data)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if stored.w != f.w || stored.h != f.h {
+		fmt.Printf("Stored board is %dx%d, not %dx%d\n", stored.w, stored.h, f.w, f.h)
+		return
+	}
+	f.CopyFrom(stored)
+}
+
 
 func (f *Board_BoolPacked) AddToStats(bs *BoardStats) {
 	for y := 0; y < f.h; y++ {
@@ -212,35 +372,116 @@ func (bs *BoardStats) MisMatchBy(mismatch int) {
 	bs.mismatch_amount = mismatch
 }
 
-// BoardIterator stores the state of a round of Conway's Game of Life.
+// BoardIterator stores the state of a round of Conway's (or a Life-like) Game of Life.
 type BoardIterator struct {
 	current, temp_internal_only *Board_BoolPacked
+	rule *Rule
 }
 
-// BoardIterator returns a new Life game state
+// BoardIterator returns a new Life game state (using the default rule)
 func NewBoardIterator(w, h int) *BoardIterator {
 	return &BoardIterator{
 		current: NewBoard_BoolPacked(w, h), 
 		temp_internal_only: NewBoard_BoolPacked(w, h),
+		rule: board_rule,
 	}
 }
 
 // Step advances the game by one instant, recomputing and updating all cells.
 func (bi *BoardIterator) Iterate(n int) {
 	for i := 0; i < n; i++ {
-		bi.current.Iterate(bi.temp_internal_only)
+		bi.current.IterateRule(bi.temp_internal_only, bi.rule)
 		// Now swap boards, to put the result in prime position
 		bi.current, bi.temp_internal_only = bi.temp_internal_only, bi.current
 	}
 }
 
+// Hash of the cells (padding included, since it's always zero outside of iteration)
+func (f *Board_BoolPacked) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, word := range f.s {
+		binary.LittleEndian.PutUint64(buf[:], word)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+type Fate int
+
+const (
+	Fate_Undetermined Fate = iota // Didn't settle down within the step limit
+	Fate_Dies
+	Fate_StillLife
+	Fate_Oscillator               // Includes spaceships that come round again on a torus
+)
+
+func (fate Fate) String() string {
+	return [...]string{"undetermined", "dies", "still-life", "oscillator"}[fate]
+}
+
+type FateReport struct {
+	fate        Fate
+	period      int // Length of the cycle (1 for still-life and dead boards)
+	cycle_start int // First step at which the board was in the cycle (0 means the board itself is in it)
+	steps       int // Number of steps actually simulated
+}
+
+func (r FateReport) String() string {
+	if r.fate == Fate_Undetermined {
+		return fmt.Sprintf("%s after %d steps", r.fate, r.steps)
+	}
+	return fmt.Sprintf("%s (period=%d, from step %d)", r.fate, r.period, r.cycle_start)
+}
+
+// Analyze runs the game forwards until the board dies, becomes a still life, or repeats an
+// earlier state (checked by hashing every state seen so far).  bi.current is left at the first repeat
+func (bi *BoardIterator) Analyze(max_steps int) FateReport {
+	history := []*Board_BoolPacked{}
+	seen := make(map[uint64][]int) // hash -> steps with that hash (collisions are checked against history)
+	
+	for step := 0; step <= max_steps; step++ {
+		h := bi.current.Hash()
+		for _, earlier := range seen[h] {
+			if bi.current.CompareTo(history[earlier], nil) == 0 {
+				fate := Fate_Oscillator
+				if step-earlier == 1 {
+					fate = Fate_StillLife
+					if bi.current.Popcount() == 0 {
+						fate = Fate_Dies
+					}
+				}
+				return FateReport{fate:fate, period:step-earlier, cycle_start:earlier, steps:step}
+			}
+		}
+		
+		snapshot := NewBoard_BoolPacked(bi.current.w, bi.current.h)
+		snapshot.CopyFrom(bi.current)
+		history = append(history, snapshot)
+		seen[h] = append(seen[h], step)
+		
+		if step < max_steps {
+			bi.Iterate(1)
+		}
+	}
+	return FateReport{fate:Fate_Undetermined, steps:max_steps}
+}
+
 type LifeProblem struct {
 	id         int
 	start, end *Board_BoolPacked
 	steps      int
+	rule       *Rule // nil means the default rule
 	// Finished, iterations, confidence, etc
 }
 
+func (problem *LifeProblem) Rule() *Rule {
+	if problem.rule == nil {
+		return board_rule
+	}
+	return problem.rule
+}
+
 func (problem *LifeProblem) CreateFake() {
 	id := problem.id
 	steps := problem.steps
@@ -259,6 +500,7 @@ func (problem *LifeProblem) CreateFake() {
 		
 		// transition it forwards 5 times
 		l := NewBoardIterator(board_width, board_height)
+		l.rule = problem.Rule()
 		l.current.CopyFrom(initial)
 		
 		l.Iterate(5)
@@ -275,7 +517,7 @@ func (problem *LifeProblem) CreateFake() {
 		//fmt.Println(end)
 		
 		// if end is not empty, then we've succeeded
-		if end.CompareTo(board_empty, nil) > 0 {
+		if end.Popcount() > 0 {
 			found = true
 			//fmt.Println("Success!")
 		}
@@ -290,6 +532,7 @@ type LifeProblemSet struct {
 	is_training bool
 	
 	transition_collection []TransitionCollectionList
+	cell_probabilities []*CellProbabilities
 }
 
 // Unlike the db, the ids here match the training.csv and test.csv files exactly
@@ -306,6 +549,7 @@ func (s *LifeProblemSet) load_csv(is_training bool, id_list []int) {
 
 // Unlike the db, the ids here match the training.csv and test.csv files exactly
 // is_training means that it contains {start[1-400],stop[1-400]} otherwise {stop[1-400]}
+// (400 is for the default 20x20 boards : In general, there are board_width*board_height columns per board)
 // has_steps means there is a steps column (true for train+test CSVs, not for submission CSV)
 func (s *LifeProblemSet) load_csv_from_file(filename string, is_training bool, has_steps bool, id_list []int) {
 	if s.problem == nil {
@@ -365,11 +609,12 @@ func (s *LifeProblemSet) load_csv_from_file(filename string, is_training bool, h
 
 			start := NewBoard_BoolPacked(board_width, board_height)
 			end := NewBoard_BoolPacked(board_width, board_height)
+			cells := start.w*start.h
 			if is_training {
-				start.LoadArray(data[0:400])
-				end.LoadArray(data[400:800])
+				start.LoadArray(data[0:cells])
+				end.LoadArray(data[cells:2*cells])
 			} else {
-				end.LoadArray(data[0:400])
+				end.LoadArray(data[0:cells])
 			}
 
 			s.problem[id] = LifeProblem{
@@ -398,10 +643,10 @@ func (s *LifeProblemSet) save_csv(filename string) { // = "data/train_fake.csv"
 
 	// Header
 	file.WriteString("id,delta")
-	for i:=1; i<=400; i++ {
+	for i:=1; i<=board_width*board_height; i++ {
 		file.WriteString(fmt.Sprintf(",start.%d", i))
 	}
-	for i:=1; i<=400; i++ {
+	for i:=1; i<=board_width*board_height; i++ {
 		file.WriteString(fmt.Sprintf(",stop.%d", i))
 	}
 	file.WriteString("\n")
@@ -452,7 +697,7 @@ func determine_kaggle_score(fake_training_data_csv string, submission_csv string
 		total_errors += error
 		total_boards++
 	}
-	score := float32(total_errors)/float32(total_boards)/400.0
+	score := float32(total_errors)/float32(total_boards)/float32(board_width*board_height)
 	return score
 }
 
@@ -463,8 +708,14 @@ func (s *LifeProblemSet) load_transition_collection(steps int) {
 	}
 	if len(s.transition_collection[steps].pre)==0 {
 		//fmt.Printf("******** STEPS OVERRIDE ************\n")
-		//s.transition_collection[steps].LoadCSV(fmt.Sprintf(TransitionCollectionFileStrFmt, 1)) 
-		s.transition_collection[steps].LoadCSV(fmt.Sprintf(TransitionCollectionFileStrFmt, steps)) 
+		//s.transition_collection[steps].LoadCSV(TransitionCollectionFile(1, board_rule)) 
+		s.transition_collection[steps].LoadCSV(TransitionCollectionFile(steps, board_rule)) 
+	}
+	if s.cell_probabilities == nil {
+		s.cell_probabilities = make([]*CellProbabilities, len(s.transition_collection))
+	}
+	if s.cell_probabilities[steps] == nil {
+		s.cell_probabilities[steps] = NewCellProbabilities(&s.transition_collection[steps])
 	}
 }
 
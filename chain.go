@@ -0,0 +1,274 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Multi-step reversal, a generation at a time : Rather than evolving start boards that have to match
+// after all the steps, find exact one-step predecessors of the end board, then predecessors of those, etc.
+// Each level keeps a beam of the most plausible boards, and if none of them lead anywhere (e.g. they're
+// all Gardens of Eden) the search backs up and tries the next-best boards of the level before.
+// Predecessors come from PredecessorSearch, or from the SAT solver if that gets stuck (or the edges aren't dead).
+
+type ChainRanking int
+
+const (
+	Chain_LiveCells  ChainRanking = iota // Fewer live cells is better
+	Chain_Likelihood                     // Higher one-step transition-table likelihood is better
+)
+
+func (r ChainRanking) String() string {
+	if r == Chain_Likelihood {
+		return "likelihood"
+	}
+	return "live-cells"
+}
+
+type ChainSolver struct {
+	rule       *Rule
+	steps      int
+	beam       int // Boards kept at each level
+	per_board  int // Predecessors enumerated for each of them
+	ranking    ChainRanking
+	likelihood *transition_likelihood // nil if there are no one-step stats (so live cells are used instead)
+	deadline   time.Time
+	random     *rand.Rand // For the order PredecessorSearch tries cells in
+
+	expanded []int // Boards whose predecessors were searched, at each level (for the report)
+}
+
+func NewChainSolver(steps int, rule *Rule, ranking ChainRanking) *ChainSolver {
+	c := &ChainSolver{
+		rule:      rule,
+		steps:     steps,
+		beam:      10,
+		per_board: 20,
+		ranking:   ranking,
+		deadline:  time.Now().Add(chain_time_limit),
+		random:    rand.New(rand.NewSource(rand.Int63())),
+		expanded:  make([]int, steps),
+	}
+	if ranking == Chain_Likelihood {
+		c.likelihood = get_transition_likelihood(rule)
+	}
+	return c
+}
+
+// How long Solver_Chain gives the chain search for each problem (unless there's a budget)
+var chain_time_limit = 60 * time.Second
+
+// How long PredecessorSearch gets on each board before the SAT solver is asked instead
+var chain_enumerate_limit = 2 * time.Second
+
+// Times the predecessors of a level's beam are sampled before giving up on it
+const chain_rounds = 3
+
+// How Solver_Chain ranks the boards at each level
+var chain_ranking = Chain_Likelihood
+
+// One-step transition counts, indexed for looking up (end patch, start patch) pairs
+type transition_likelihood struct {
+	freq  map[Patch]map[Patch]int // [canonical end patch][start patch in the same orientation]
+	total map[Patch]int
+}
+
+func new_transition_likelihood(tc *TransitionCollectionList) *transition_likelihood {
+	tl := &transition_likelihood{
+		freq:  make(map[Patch]map[Patch]int),
+		total: make(map[Patch]int),
+	}
+	for end, pl := range tc.pre {
+		starts := make(map[Patch]int, len(pl.starts))
+		for _, pf := range pl.starts {
+			starts[pf.patch] = pf.freq
+		}
+		tl.freq[end] = starts
+		tl.total[end] = pl.freq_total
+	}
+	return tl
+}
+
+// LogLikelihood of start being one step before end, patch by patch.  Start patches never seen for
+// an end patch get half a count (unlikely, but not impossible), and unseen end patches don't count
+func (tl *transition_likelihood) LogLikelihood(start, end *Board_BoolPacked) float64 {
+	ll := 0.0
+	for y := 0; y < end.h; y++ {
+		for x := 0; x < end.w; x++ {
+			oriented := end.MakePatch(x, y).BestOrientation()
+			starts, ok := tl.freq[oriented.patch]
+			if !ok {
+				continue
+			}
+			freq := float64(starts[oriented.Apply(start.MakePatch(x, y))])
+			if freq == 0 {
+				freq = 0.5
+			}
+			ll += math.Log(freq / float64(tl.total[oriented.patch]))
+		}
+	}
+	return ll
+}
+
+// Loaded once per rule (and shared between workers)
+var transition_likelihoods = make(map[string]*transition_likelihood)
+var transition_likelihoods_lock sync.Mutex
+
+func get_transition_likelihood(rule *Rule) *transition_likelihood {
+	transition_likelihoods_lock.Lock()
+	defer transition_likelihoods_lock.Unlock()
+	if tl, ok := transition_likelihoods[rule.String()]; ok {
+		return tl
+	}
+	var tc TransitionCollectionList
+	tc.LoadCSV(TransitionCollectionFile(1, rule))
+	var tl *transition_likelihood
+	if len(tc.pre) > 0 {
+		tl = new_transition_likelihood(&tc)
+	}
+	transition_likelihoods[rule.String()] = tl
+	return tl
+}
+
+type chain_candidate struct {
+	board *Board_BoolPacked
+	score float64 // Summed over the levels so far : Higher is better
+}
+
+func (c *ChainSolver) score(start, end *Board_BoolPacked) float64 {
+	if c.ranking == Chain_Likelihood && c.likelihood != nil {
+		return c.likelihood.LogLikelihood(start, end)
+	}
+	return -float64(start.Popcount())
+}
+
+// All the predecessors found for the beam that haven't been seen before, best first
+func (c *ChainSolver) expand(beam []chain_candidate, seen map[string]bool) []chain_candidate {
+	candidates := []chain_candidate{}
+	for _, parent := range beam {
+		add := func(start *Board_BoolPacked) {
+			key := string(start.toBinary())
+			if seen[key] {
+				return
+			}
+			seen[key] = true
+			candidates = append(candidates, chain_candidate{start, parent.score + c.score(start, parent.board)})
+		}
+		// Restart in a different random order for each one, so they're not all the same but for the last rows
+		n, complete := 0, false
+		if ps, err := NewPredecessorSearch(parent.board, c.rule); err == nil {
+			ps.deadline = time.Now().Add(chain_enumerate_limit)
+			if ps.deadline.After(c.deadline) {
+				ps.deadline = c.deadline
+			}
+			ps.random = rand.New(rand.NewSource(c.random.Int63()))
+			for i := 0; i < c.per_board && !complete && !time.Now().After(ps.deadline); i++ {
+				var found int
+				found, complete = ps.Enumerate(1, add)
+				n += found
+			}
+		}
+		if n == 0 && !complete {
+			sat_predecessors(parent.board, c.rule, c.per_board, c.deadline, add)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	return candidates
+}
+
+// Up to n distinct one-step predecessors from the SAT solver : Each one found is ruled out before the next Solve()
+func sat_predecessors(end *Board_BoolPacked, rule *Rule, n int, deadline time.Time, visit func(start *Board_BoolPacked)) int {
+	cnf := NewLifeCNF(end, 1, rule)
+	s := NewSatSolver()
+	cnf.LoadInto(s)
+	found := 0
+	for found < n && s.Solve(SatLimit{deadline: deadline}) == Sat_Satisfiable {
+		visit(cnf.Board(0, s.Value))
+		found++
+		block := make([]int, len(cnf.cells[0]))
+		for i, v := range cnf.cells[0] {
+			block[i] = v
+			if s.Value(v) {
+				block[i] = -v
+			}
+		}
+		if !s.AddClause(block...) {
+			break
+		}
+	}
+	return found
+}
+
+// Returns the start board at the end of the best chain back from this level's beam,
+// or nil if there isn't one (or time ran out)
+func (c *ChainSolver) reverse(level int, beam []chain_candidate) *Board_BoolPacked {
+	if level == c.steps {
+		return beam[0].board
+	}
+	c.expanded[level] += len(beam)
+
+	// Boards with orphans can't be reached from anything, so are only any good as the start board
+	more_levels := level+1 < c.steps
+	seen := make(map[string]bool)
+	for round := 0; round < chain_rounds; round++ { // The predecessors found are a sample, so there may be more
+		candidates := c.expand(beam, seen)
+		if len(candidates) == 0 {
+			return nil
+		}
+		next := []chain_candidate{}
+		for _, candidate := range candidates {
+			if time.Now().After(c.deadline) {
+				return nil
+			}
+			if more_levels && candidate.board.FindOrphans(c.rule, nil).orphans > 0 {
+				continue
+			}
+			next = append(next, candidate)
+			if len(next) == c.beam {
+				if start := c.reverse(level+1, next); start != nil {
+					return start
+				}
+				next = []chain_candidate{} // Backtrack : Try the next-best boards at this level
+			}
+		}
+		if len(next) > 0 {
+			if start := c.reverse(level+1, next); start != nil {
+				return start
+			}
+		}
+	}
+	return nil
+}
+
+// Solve returns a start board that gives end after c.steps, or nil if none was found in time
+func (c *ChainSolver) Solve(end *Board_BoolPacked) *Board_BoolPacked {
+	return c.reverse(0, []chain_candidate{{end, 0}})
+}
+
+// Solver_Chain (-type=chain on the run command) : If no chain is found in time, the GA takes over
+// for whatever is left of the budget
+type Solver_Chain struct{}
+
+func (Solver_Chain) Solve(problem LifeProblem, lps *LifeProblemSet, budget time.Duration, random *rand.Rand) *IndividualResult {
+	c := NewChainSolver(problem.steps, problem.Rule(), chain_ranking)
+	if budget > 0 {
+		c.deadline = time.Now().Add(budget)
+	}
+	c.random = random
+	if start := c.Solve(problem.end); start != nil {
+		return solution_result(problem, start, lps.is_training)
+	}
+	fmt.Printf("problem[%d] : No chain of predecessors found (boards expanded per level %v), using GA\n", problem.id, c.expanded)
+	ga_deadline := time.Time{} // No budget : The GA runs its course
+	if budget > 0 {
+		ga_deadline = c.deadline
+	}
+	return create_solution_until(problem, lps, ga_deadline)
+}
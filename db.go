@@ -38,8 +38,15 @@ CREATE TABLE `solutions` (
 	`mtsf` int(11) DEFAULT NULL, 
 	`mtef` int(11) NOT NULL, 
 	`start` text NOT NULL, 
+	`params` text DEFAULT NULL, 
 	KEY `solutions_id` (`id`) 
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1
+
+`start` is either the 400 character '0'/'1' form (toCompactString), 
+or (since migrate_solutions_to_binary) the 'b64:...' packed form (toBase64)
+
+`params` is the GA parameters used, as JSON (see GAParams), or NULL for solutions without them
+(it's added to older databases by migrate_solutions_add_params, which the run command calls before starting)
 */
 
 func get_db_connection() *sql.DB {
@@ -314,21 +321,27 @@ func save_solution_to_db(id int, steps int, seed int, individual_result *Individ
 	}
 	
 	// insert into the solutions db
-	_, err := db.Exec("INSERT INTO solutions SET id=?, steps=?, seed=?, version=?, iter=?,"+
-						" ones_i=?, mtsi=?, mtei=?,"+
-						" ones_f=?, mtsf=?, mtef=?,"+
-						" start=?",
-						id, steps, seed,
-						currently_running_version, 
-						individual_result.iter, 
-						individual_result.true_start_1s, 
-						individual_result.mismatch_from_true_start_initial, 
-						individual_result.mismatch_from_true_end_initial, 
-						individual_result.true_end_1s, 
-						individual_result.mismatch_from_true_start_final, 
-						individual_result.mismatch_from_true_end_final, 
-						individual_result.individual.start.toCompactString(),
-					)
+	query := "INSERT INTO solutions SET id=?, steps=?, seed=?, version=?, iter=?,"+
+				" ones_i=?, mtsi=?, mtei=?,"+
+				" ones_f=?, mtsf=?, mtef=?,"+
+				" start=?"
+	args := []interface{}{
+				id, steps, seed,
+				currently_running_version, 
+				individual_result.iter, 
+				individual_result.true_start_1s, 
+				individual_result.mismatch_from_true_start_initial, 
+				individual_result.mismatch_from_true_end_initial, 
+				individual_result.true_end_1s, 
+				individual_result.mismatch_from_true_start_final, 
+				individual_result.mismatch_from_true_end_final, 
+				individual_result.individual.start.toBase64(),
+			}
+	if individual_result.params != "" { // Only named when there are some, so older tables still work for the other solvers
+		query += ", params=?"
+		args = append(args, individual_result.params)
+	}
+	_, err := db.Exec(query, args...)
 	if err != nil {
 		fmt.Println("Inserting into solutions table for individual Error:", err)
 		return
@@ -342,7 +355,90 @@ func save_solution_to_db(id int, steps int, seed int, individual_result *Individ
 	}
 }
 
+// Re-encode the existing '0'/'1' start boards in the packed form (8x smaller)
+func migrate_solutions_to_binary() {
+	db := get_db_connection()
+	defer db.Close()
+	
+	rows, err := db.Query("SELECT id, seed, version, start FROM solutions WHERE start NOT LIKE ?", BoardBase64Prefix+"%")
+	if err != nil {
+		fmt.Println("Query unmigrated solutions Error:", err)
+		return
+	}
+	defer rows.Close()
+	
+	// There's no primary key, so the old start string is part of the row identification
+	update, err := db.Prepare("UPDATE solutions SET start=? WHERE id=? AND seed=? AND version=? AND start=?")
+	if err != nil {
+		fmt.Println("Update 'start' Prepare Error:", err)
+		return
+	}
+	defer update.Close()
+	
+	count, bytes_before, bytes_after := 0, 0, 0
+	for rows.Next() {
+		var id, seed, version int
+		var start string
+		err = rows.Scan(&id, &seed, &version, &start)
+		if err != nil {
+			fmt.Println("Query unmigrated solution row Error:", err)
+			return
+		}
+		
+		board := NewBoard_BoolPacked(board_width, board_height)
+		board.fromCompactString(start)
+		if board.toCompactString() != start {
+			fmt.Printf("Solution for id=%d seed=%d doesn't look like a %dx%d board : skipping\n", id, seed, board_width, board_height)
+			continue
+		}
+		encoded := board.toBase64()
+		
+		_, err = update.Exec(encoded, id, seed, version, start)
+		if err != nil {
+			fmt.Println("Update 'start' Exec Error:", err)
+			return
+		}
+		
+		count++
+		bytes_before += len(start)
+		bytes_after  += len(encoded)
+		if count % 10000 == 0 {
+			fmt.Printf("Migrated %d solutions (%d -> %d bytes)\n", count, bytes_before, bytes_after)
+		}
+	}
+	fmt.Printf("Migrated %d solutions in total (%d -> %d bytes)\n", count, bytes_before, bytes_after)
+}
+
+// Add the `params` column to a solutions table made before it existed (if it isn't there already).
+// The run command does this itself before starting, so that no solutions are lost.  Returns false on error
+func migrate_solutions_add_params() bool {
+	db := get_db_connection()
+	defer db.Close()
+	
+	var columns int
+	err := db.QueryRow("SELECT COUNT(*) FROM information_schema.COLUMNS"+
+						" WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME='solutions' AND COLUMN_NAME='params'").Scan(&columns)
+	if err != nil {
+		fmt.Println("Checking for 'params' column Error:", err)
+		return false
+	}
+	if columns > 0 {
+		return true
+	}
+	
+	_, err = db.Exec("ALTER TABLE solutions ADD COLUMN params text DEFAULT NULL")
+	if err != nil {
+		fmt.Println("Adding 'params' column Error:", err)
+		return false
+	}
+	fmt.Println("Added 'params' column to solutions")
+	return true
+}
+
 // only_submit_for_steps_equals : Set this for +ve to filter submission to include only specific steps answers (rest are zeroed as a base-line)
+// Votes that the per-cell probability map gets alongside the solutions (0 = don't use it)
+var submission_prior_weight = 0
+
 func create_submission(fname string, is_training bool, only_submit_for_steps_equals int) {
 	id_list := []int{}
 	
@@ -357,6 +453,19 @@ func create_submission(fname string, is_training bool, only_submit_for_steps_equ
 		}
 	}
 	
+	// The probability maps need the end boards, which are in the csv files (with +ve ids)
+	var kaggle LifeProblemSet
+	if submission_prior_weight>0 {
+		csv_id_list := []int{}
+		for _, id := range id_list {
+			if id<0 {
+				id = -id
+			}
+			csv_id_list = append(csv_id_list, id)
+		}
+		kaggle.load_csv(is_training, csv_id_list)
+	}
+	
 	db := get_db_connection()
 	defer db.Close()
 	
@@ -368,7 +477,7 @@ func create_submission(fname string, is_training bool, only_submit_for_steps_equ
 	defer file.Close()
 	
 	file.WriteString("id")
-	for i:=1; i<=400; i++ {
+	for i:=1; i<=board_width*board_height; i++ {
 		file.WriteString(fmt.Sprintf(",start.%d", i))
 	}
 	file.WriteString("\n")
@@ -450,7 +559,7 @@ func create_submission(fname string, is_training bool, only_submit_for_steps_equ
 			}
 			
 			start_board := NewBoard_BoolPacked(board_width, board_height)
-			start_board.fromCompactString(start)
+			start_board.fromStoredString(start)
 			
 			// Do every entry twice ( so that an additional +1 for the best will tie-break a 50/50 threshold)
 			start_board.AddToStats(stats)
@@ -542,6 +651,16 @@ func create_submission(fname string, is_training bool, only_submit_for_steps_equ
 			count_ids_found++
 		}
 		
+		if submission_prior_weight>0 && samples_found>0 {
+			csv_id := id
+			if csv_id<0 {
+				csv_id = -csv_id
+			}
+			if problem, ok := kaggle.problem[csv_id]; ok {
+				kaggle.cell_probabilities_for(problem.steps).Map(problem.end).AddToStats(stats, submission_prior_weight)
+			}
+		}
+		
 		//fmt.Println(stats)
 		
 		// Ok, so now let's figure out a board from these stats that's a better guess
@@ -0,0 +1,154 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"math/rand"
+)
+
+// Diversity preservation for the GA : Every individual starts as a copy of the target, and 90% tournament
+// pressure soon fills the population with copies of a few boards.  Each of these can be switched on
+// with the GA parameters (see profile.go) :
+//   dedup=1         : After each generation is made, boards that are already in it are mutated (or, failing
+//                     that, replaced by an immigrant), so the slots aren't wasted on copies
+//   crowding=1      : Deterministic crowding : Parents are paired off at random (rather than by tournaments),
+//                     each pair makes two children, and each child competes with whichever parent it's closer to
+//                     (by Hamming distance), only taking that parent's place if it's no worse.  So good boards
+//                     can't take over the population, only hold onto their own niche
+//   immigrant_pct=N : N% of each generation are new random boards (sampled from the prior if there is one)
+
+// How many tries at mutating a duplicate into something new, before giving up and making an immigrant
+const dedup_tries = 3
+
+// pick_parent : With crowding, slot counter's parent is fixed by order, otherwise it's a tournament
+func (prev *Population) pick_parent(order []int, counter int) *Individual {
+	if order != nil {
+		return prev.individual[order[counter]]
+	}
+	return prev.PickIndividualWithPressure()
+}
+
+// pick_partner : The second parent for a crossover.  With crowding, it's the parent of the other slot in the
+// pair (see PairWithParents), otherwise another tournament
+func (prev *Population) pick_partner(order []int, counter int) *Individual {
+	if order != nil {
+		partner := counter + 1
+		if counter%2 == 0 {
+			partner = counter - 1
+		}
+		if 1 <= partner && partner < len(order) {
+			return prev.individual[order[partner]]
+		}
+		return prev.individual[order[counter]] // The odd one out at the end only has its own parent
+	}
+	return prev.PickIndividualWithPressure()
+}
+
+// MakeImmigrant sets individual to a new random board, unrelated to anything in the population
+func (pop *Population) MakeImmigrant(individual *Individual) {
+	if pop.prior != nil {
+		w, h := individual.start.w, individual.start.h
+		radius := w
+		if h > radius {
+			radius = h
+		}
+		individual.start.SampleFrom(pop.prior, w/2, h/2, radius, pop.random) // Every cell
+	} else {
+		density := float32(pop.target.Popcount()) / float32(pop.target.w*pop.target.h)
+		for y := 0; y < individual.start.h; y++ {
+			for x := 0; x < individual.start.w; x++ {
+				individual.start.Set(x, y, pop.random.Float32() < density)
+			}
+		}
+	}
+	individual.op, individual.radius, individual.parent = Op_None, 0, nil
+	individual.fitness = 0
+}
+
+// RemakeDuplicates leaves every board in the population different (keeping the first of any copies,
+// so the elite in [0] stays put)
+func (pop *Population) RemakeDuplicates() {
+	seen := make(map[uint64]bool, len(pop.individual))
+	for _, individual := range pop.individual {
+		hash := individual.start.Hash()
+		for try := 0; seen[hash] && try < dedup_tries; try++ {
+			individual.start.MutateMask(pop.target, pop.mutation_loop_pct, pop.mutation_radius, pop.random)
+			individual.op, individual.radius = Op_None, 0 // Not the operator's own work any more
+			hash = individual.start.Hash()
+		}
+		if seen[hash] {
+			pop.MakeImmigrant(individual)
+			hash = individual.start.Hash()
+		}
+		seen[hash] = true
+	}
+}
+
+// PairWithParents : For deterministic crowding, the slots after the elite in [0] go in pairs (1,2), (3,4)...
+// whose children were made from the same two parents.  Each child is set to compete with the nearer parent :
+// Whichever way round makes the total Hamming distance between children and parents smaller
+func (pop *Population) PairWithParents() {
+	for c := 1; c+1 < len(pop.individual); c += 2 {
+		c1, c2 := pop.individual[c], pop.individual[c+1]
+		if c1.parent == nil || c2.parent == nil { // An immigrant doesn't compete for a place
+			continue
+		}
+		p1, p2 := c1.parent, c2.parent
+		straight := c1.start.CompareTo(p1.start, nil) + c2.start.CompareTo(p2.start, nil)
+		crossed := c1.start.CompareTo(p2.start, nil) + c2.start.CompareTo(p1.start, nil)
+		if crossed < straight {
+			c1.parent, c2.parent = p2, p1
+		}
+	}
+}
+
+// Crowd : Once the generation's fitnesses are in, a child that's worse than its parent is replaced by
+// (a copy of) the parent.  Only does anything with crowding, since otherwise the parents aren't recorded
+func (pop *Population) Crowd() {
+	for _, individual := range pop.individual {
+		parent := individual.parent
+		if parent != nil && parent.fitness > individual.fitness {
+			individual.start.CopyFrom(parent.start)
+			individual.diff.CopyFrom(parent.diff)
+			individual.fitness = parent.fitness
+		}
+	}
+}
+
+// Diversity is the mean Hamming distance between pairs of individuals : Over every pair, if there are
+// no more than 'pairs' of them, otherwise over that many pairs chosen at random
+func (pop *Population) Diversity(pairs int) float64 {
+	n := len(pop.individual)
+	if n < 2 {
+		return 0
+	}
+	total, count := 0, 0
+	if n*(n-1)/2 <= pairs {
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				total += pop.individual[i].start.CompareTo(pop.individual[j].start, nil)
+				count++
+			}
+		}
+	} else {
+		random := rand.New(rand.NewSource(1)) // Don't disturb the GA's own random sequence
+		for ; count < pairs; count++ {
+			i, j := random.Intn(n), random.Intn(n-1)
+			if j >= i {
+				j++
+			}
+			total += pop.individual[i].start.CompareTo(pop.individual[j].start, nil)
+		}
+	}
+	return float64(total) / float64(count)
+}
+
+// Distinct is the number of different boards in the population
+func (pop *Population) Distinct() int {
+	seen := make(map[uint64]bool, len(pop.individual))
+	for _, individual := range pop.individual {
+		seen[individual.start.Hash()] = true
+	}
+	return len(seen)
+}
@@ -14,6 +14,11 @@ type Individual struct {
 	start *Board_BoolPacked
 	diff  *Board_BoolPacked
 	fitness int  // higher is better, no particular scale
+	
+	op GAOperator // How GenerationAfter made this one (see adapt.go)
+	radius int // The mutation radius it used, if it was chosen adaptively (else 0)
+	parent_fitness int // Of its (fitter) parent, to see whether op did any good
+	parent *Individual // With crowding, the one whose place it has to earn (see diversity.go)
 }
 
 /*
@@ -37,41 +42,84 @@ type Population struct {
 	crossover_pct int // (0..100)
 	
 	transition_collection *TransitionCollectionList
+	
+	batch *BoardBatch // Created on first use by EvaluateMismatches
+	
+	unfixable *Board_BoolPacked // Target cells that no start can get right (see FindOrphans), so mutation shouldn't chase them
+	chase     *Board_BoolPacked // Scratch space for diff-without-unfixable
+	block     *Board_BoolPacked // Scratch space for CrossoverFrom
+	
+	prior *ProbabilityMap // P(start cell alive) from the transition stats, for patches with no known start
+	
+	random *rand.Rand // For all the population's choices (shared_random unless SetRandom is called)
+	
+	adapt *GAAdapter // If not nil, chooses the operators and mutation radius instead of the fixed percentages
+	
+	dedup bool // Diversity preservation (see diversity.go)
+	crowding bool
+	immigrant_pct int // (0..100)
 }
 
-func NewPopulation(size int, radius int, target *Board_BoolPacked, tc *TransitionCollectionList) *Population {
+func NewPopulation(size int, params GAParams, target *Board_BoolPacked, tc *TransitionCollectionList) *Population {
 	//fmt.Printf("NewPopulation(size=%d)\n", size)
 	ind := make([]*Individual, size)
 	for i:=0; i<size; i++ {
 		ind[i] = &Individual{ 
-			                  start:NewBoard_BoolPacked(board_width, board_height), 
-			                  diff: NewBoard_BoolPacked(board_width, board_height), 
+			                  start:NewBoard_BoolPacked(target.w, target.h), // Sized (and bounded) like the target, whatever -width/-height say
+			                  diff: NewBoard_BoolPacked(target.w, target.h), 
 		                      fitness:0,
 		                    }
+		ind[i].start.boundary, ind[i].diff.boundary = target.boundary, target.boundary
 	}
 	//fmt.Printf("NewPopulation(size=%d) inited\n", size)
 	return &Population{
 		individual:ind,
 		target:target,
 		transition_collection:tc,
+		block:NewBoard_BoolPacked(target.w, target.h),
+		
+		pressure_pct:params.PressurePct,  // pressure_pct is in (50..100) = Prob(Chose better of two random individuals)
+		
+		mutation_pct:params.MutationPct,
+		mutation_loop_pct:params.MutationLoopPct,
+		mutation_radius:params.MutationRadius,
 		
-		pressure_pct:1*90+0*100,  // pressure_pct is in (50..100) = Prob(Chose better of two random individuals)
+		crossover_pct:params.CrossoverPct,
 		
-		mutation_pct:1*50+0*100,
-		mutation_loop_pct:70,
-		mutation_radius:radius,
+		random:shared_random,
 		
-		crossover_pct:30*1,
+		dedup:params.Dedup>0,
+		crowding:params.Crowding>0,
+		immigrant_pct:params.ImmigrantPct,
 	}
 }
 
+func (pop *Population) SetUnfixable(unfixable *Board_BoolPacked) {
+	pop.unfixable = unfixable
+	pop.chase = NewBoard_BoolPacked(unfixable.w, unfixable.h)
+}
+
+func (pop *Population) SetPrior(prior *ProbabilityMap) {
+	pop.prior = prior
+}
+
+// SetRandom : The same random should be set on both populations that take turns
+func (pop *Population) SetRandom(random *rand.Rand) {
+	pop.random = random
+}
+
+// SetAdapter : The same adapter should be set on both populations that take turns
+func (pop *Population) SetAdapter(adapt *GAAdapter) {
+	pop.adapt = adapt
+}
+
 func (p *Population) OrderIndividualsBasedOnFitness(i_1, i_2 *Individual) (*Individual,*Individual) {  
 /*  This is potentially too-clever-by-half
 	if i_1.fitness == i_2.fitness {
 		// Secondary pressure to minimize count of on cells in starting board
 		
-		count_on_1 := i_1.start.CompareTo(board_empty, nil)
-		count_on_2 := i_2.start.CompareTo(board_empty, nil)
+		count_on_1 := i_1.start.Popcount()
+		count_on_2 := i_2.start.Popcount()
 		
 		if count_on_1 > count_on_2 {
 			i_1,i_2 = i_2,i_1 // Switch them so that i_1 is the fitter (lower is better for initial positions) of the two
@@ -86,17 +134,17 @@ func (p *Population) OrderIndividualsBasedOnFitness(i_1, i_2 *Individual) (*Indi
 
 func (p *Population) PickIndividualWithPressure() *Individual {  
 	// Pick two individuals at random from population
-	i_1_pos := rand.Intn(len(p.individual))
+	i_1_pos := p.random.Intn(len(p.individual))
 	i_1 := p.individual[i_1_pos]
 	
-	i_2_pos := rand.Intn(len(p.individual))
+	i_2_pos := p.random.Intn(len(p.individual))
 	i_2 := p.individual[i_2_pos]
 	
 	i_1, i_2 = p.OrderIndividualsBasedOnFitness(i_1, i_2)
 	
 	// if pct< a threshold, pick the better one
 	i_chosen := i_1
-	if rand.Intn(100) > p.pressure_pct { // i.e. only sometimes do the opposite
+	if p.random.Intn(100) > p.pressure_pct { // i.e. only sometimes do the opposite
 		i_chosen = i_2
 	}
 	//fmt.Printf("Individuals {%d:%d} Fitnesses : {%d:%d} -> %d\n", i_1_pos, i_2_pos, i_1.fitness, i_2.fitness, i_chosen.fitness)
@@ -112,43 +160,110 @@ func (pop *Population) BestIndividual() *Individual {
 	return i_best
 }
 
+// Run every individual forwards 'steps', and return its mismatch vs the target (storing the diff)
+// This is done BoardBatchSize individuals at a time, in bit-sliced form
+func (pop *Population) EvaluateMismatches(steps int, rule *Rule, known_mask *Board_BoolPacked) []int {
+	if pop.batch == nil || pop.batch.rule != rule {
+		pop.batch = NewBoardBatch(pop.target.w, pop.target.h, rule)
+	}
+	
+	mismatches := make([]int, 0, len(pop.individual))
+	starts := make([]*Board_BoolPacked, 0, BoardBatchSize)
+	diffs  := make([]*Board_BoolPacked, 0, BoardBatchSize)
+	for lo := 0; lo < len(pop.individual); lo += BoardBatchSize {
+		starts, diffs = starts[:0], diffs[:0]
+		for i := lo; i < lo+BoardBatchSize && i < len(pop.individual); i++ {
+			starts = append(starts, pop.individual[i].start)
+			diffs  = append(diffs,  pop.individual[i].diff)
+		}
+		pop.batch.Load(starts)
+		pop.batch.Iterate(steps)
+		mismatches = append(mismatches, pop.batch.CompareTo_Masked(pop.target, diffs, known_mask)...)
+	}
+	return mismatches
+}
+
 func (pop *Population) GenerationAfter(prev *Population) {
+	var order []int // With crowding, the parent of each slot
+	if pop.crowding {
+		order = pop.random.Perm(len(prev.individual))
+	}
+	
 	// Fill in every slot
 	for counter, individual := range pop.individual {
 		if counter==0 { // Reserve position 0 for a copy of the previous generation's best individual
 			best_individual := prev.BestIndividual()
 			individual.start.CopyFrom(best_individual.start)
 			individual.fitness = best_individual.fitness
+			individual.op, individual.parent = Op_None, nil
 			continue
 		}
 		
-		choser := rand.Intn(100)
-		if 0<=choser && choser < pop.crossover_pct { 
+		if pop.immigrant_pct>0 && pop.random.Intn(100) < pop.immigrant_pct {
+			pop.MakeImmigrant(individual)
+			continue
+		}
+		individual.parent = nil
+		
+		choser := pop.random.Intn(100)
+		op := Op_Copy
+		if pop.adapt!=nil && choser < (pop.crossover_pct + pop.mutation_pct) {
+			op = pop.adapt.ChooseOperator(pop.random) // The same share of the population, split adaptively
+		} else if 0<=choser && choser < pop.crossover_pct {
+			op = Op_Crossover
+		} else if pop.crossover_pct<=choser && choser < (pop.crossover_pct + pop.mutation_pct) {
+			op = Op_TargetOverlay
+			if pop.random.Intn(100)>20 {
+				op = Op_DiffOverlay
+			}
+		}
+		individual.op, individual.radius = op, 0
+		
+		if op==Op_Crossover { 
 			// Do a 'crossover copy' from two individuals in previous population to this one
-			parent_1 := prev.PickIndividualWithPressure()
-			parent_2 := prev.PickIndividualWithPressure()
-			individual.start.CrossoverFrom(parent_1.start, parent_2.start)
+			parent_1 := prev.pick_parent(order, counter)
+			parent_2 := prev.pick_partner(order, counter)
+			individual.start.CrossoverFrom(parent_1.start, parent_2.start, pop.block, pop.random)
+			parent_better,_ := prev.OrderIndividualsBasedOnFitness(parent_1, parent_2)
+			individual.parent_fitness = parent_better.fitness
+			if pop.crowding {
+				individual.parent = parent_1
+			}
 		} else { // Do a simple copy, with the possibility of mutation (below)
-			i_chosen := prev.PickIndividualWithPressure()
+			i_chosen := prev.pick_parent(order, counter)
 			individual.start.CopyFrom(i_chosen.start)
-			if pop.crossover_pct<=choser && choser < (pop.crossover_pct + pop.mutation_pct) {
+			individual.parent_fitness = i_chosen.fitness
+			if pop.crowding {
+				individual.parent = i_chosen
+			}
+			if op==Op_DiffOverlay || op==Op_TargetOverlay {
 				//individual.start.MutateRadiusBits(pop.mutation_loop_pct, pop.mutation_radius) // % do additional mutation, radius of action
 				
+				radius := pop.mutation_radius
+				if pop.adapt!=nil {
+					radius = pop.adapt.ChooseRadius(pop.random)
+					individual.radius = radius
+				}
+				
 				x,y := -1,-1
-				if rand.Intn(100)>20 {
+				if op==Op_DiffOverlay {
 					// For this individual, pick a position in the diff
-					x,y = i_chosen.diff.RandomBitPosition()
+					chase := i_chosen.diff
+					if pop.unfixable != nil { // Leave alone the errors that can't be fixed
+						chase = pop.chase.AndNot(i_chosen.diff, pop.unfixable)
+					}
+					x,y = chase.RandomBitPosition(pop.random)
 				} else {
 					// For this individual, pick a position in the target, just for a change
-					x,y = pop.target.RandomBitPosition()
+					x,y = pop.target.RandomBitPosition(pop.random)
 				}
 				
 				if x>=0 && y>=0 {
 					// Offset by a little bit...
 					if true {
 						//fmt.Printf("target_error@(%2d,%2d):\n", x,y)
-						x = CoordWithinRadius(x, i_chosen.diff.w, pop.mutation_radius/2+1)
-						y = CoordWithinRadius(y, i_chosen.diff.h, pop.mutation_radius/2+1)
+						x = CoordWithinRadius(x, i_chosen.diff.w, radius/2+1, pop.random)
+						y = CoordWithinRadius(y, i_chosen.diff.h, radius/2+1, pop.random)
 					}
 				} else {
 					// There are no errors...  So we don't have a basis for complaining, really
@@ -157,13 +272,13 @@ func (pop *Population) GenerationAfter(prev *Population) {
 					if true {
 						//fmt.Printf("No errors to mutate around : Try using the target instead of the diff\n")
 						//fmt.Println(i_chosen.start) // Check
-						x,y = pop.target.RandomBitPosition()
+						x,y = pop.target.RandomBitPosition(pop.random)
 						//fmt.Println("*** Isn't the end image DEFINED to be non-blank? ***")
 					}
 					
 					if false {
 						//fmt.Printf("No errors to mutate around : Try zeroing out bits in the start\n")
-						individual.start.MutateMask(individual.start, pop.mutation_loop_pct, 0) // % do additional mutation, radius of action
+						individual.start.MutateMask(individual.start, pop.mutation_loop_pct, 0, pop.random) // % do additional mutation, radius of action
 						x,y = -1,-1 // Don't do the overlay thing
 					}
 				}
@@ -172,7 +287,7 @@ func (pop *Population) GenerationAfter(prev *Population) {
 					//fmt.Printf("Examining patch(%8d) from target @(%2d,%2d):\n", int(end), x,y)
 					//fmt.Print(end)
 					
-					start_random := pop.transition_collection.GetRandomEntry_OrientationCompensated(end)
+					start_random := pop.transition_collection.GetRandomEntry_OrientationCompensated(end, pop.random)
 					if start_random>=0 { // Yes - we have an overlay to try...
 						//fmt.Print("Suggested Start :\n")
 						//fmt.Print(start_random)
@@ -186,14 +301,19 @@ func (pop *Population) GenerationAfter(prev *Population) {
 						
 						// Use the chosen individual bits as a bit mask instead (i.e. DO SOMETHING)
 						
+						if pop.prior!=nil {
+							// Cell-by-cell guess from the per-cell probabilities (which are known even for unseen patches)
+							individual.start.SampleFrom(pop.prior, x,y, 1, pop.random)
+						}
+						
 						if false {
 							//fmt.Printf("Introducing random noise\n")
-							individual.start.MutateMask(individual.start, pop.mutation_loop_pct, pop.mutation_radius)
+							individual.start.MutateMask(individual.start, pop.mutation_loop_pct, pop.mutation_radius, pop.random)
 						}
 						
 						if false {
 							//fmt.Printf("Introducing random zeroing\n")
-							individual.start.MutateMask(individual.start, pop.mutation_loop_pct, 0) // % do additional mutation, radius of action
+							individual.start.MutateMask(individual.start, pop.mutation_loop_pct, 0, pop.random) // % do additional mutation, radius of action
 						}
 					}
 				}
@@ -203,6 +323,13 @@ func (pop *Population) GenerationAfter(prev *Population) {
 
 		individual.fitness = 0
 	}
+	
+	if pop.dedup {
+		pop.RemakeDuplicates()
+	}
+	if pop.crowding {
+		pop.PairWithParents()
+	}
 }
 
 type IndividualResult struct {
@@ -211,47 +338,74 @@ type IndividualResult struct {
 	mismatch_from_true_end_initial, mismatch_from_true_end_final int
 	true_start_1s, true_end_1s int
 	iter int
+	elapsed time.Duration // Filled in by solve_problem
+	params string // The GA parameters used (as JSON), saved with the solution
 }
 
 func create_solution(problem LifeProblem, lps *LifeProblemSet) *IndividualResult {
+	return create_solution_until(problem, lps, time.Time{})
+}
+
+// As create_solution, but giving up at the deadline (if it's not zero) with the best so far
+func create_solution_until(problem LifeProblem, lps *LifeProblemSet, deadline time.Time) *IndividualResult {
+	params := ga_params(problem.steps) // See -profile and -ga in main()
+	
 	// Create a population of potential boards
-	pop_size := 1000
-	pop := NewPopulation(pop_size, problem.steps, problem.end, &lps.transition_collection[problem.steps])
+	pop_size := params.PopSize
+	pop := NewPopulation(pop_size, params, problem.end, &lps.transition_collection[problem.steps])
 	for i:=0; i<pop_size; i++ {
 		// Create a candidate starting point
 		// NB:  We can only work from the problem.end
 		pop.individual[i].start.CopyFrom(problem.end)
 	}
 	
-	p_temp := NewPopulation(pop_size, problem.steps, problem.end, &lps.transition_collection[problem.steps])
+	p_temp := NewPopulation(pop_size, params, problem.end, &lps.transition_collection[problem.steps])
 
-	l := NewBoardIterator(board_width, board_height)
-	
 	var best_individual *Individual
-	best_individual_start := NewBoard_BoolPacked(board_width, board_height)
+	best_individual_start := NewBoard_BoolPacked(problem.end.w, problem.end.h)
 	
 	mismatch_from_true_start_initial, mismatch_from_true_start_latest, true_start_1s := -999,-999,-999
 	mismatch_from_true_end_initial, mismatch_from_true_end_latest, true_end_1s := 0,0,0
 	
 	if lps.is_training {
-		true_start_1s = problem.start.CompareTo(board_empty, nil)
+		true_start_1s = problem.start.Popcount()
+	}
+	true_end_1s = problem.end.Popcount()
+	
+	// For Boundary_Unknown, don't penalize cells that the outside could have reached
+	known_mask := problem.end.KnownMask(problem.steps)
+	
+	// Garden-of-Eden parts of the target can't be matched, however long we run for
+	orphans := problem_orphans(problem, known_mask)
+	if orphans.orphans > 0 {
+		pop.SetUnfixable(orphans.unfixable)
+		p_temp.SetUnfixable(orphans.unfixable)
+	}
+	
+	if lps.cell_probabilities!=nil && lps.cell_probabilities[problem.steps]!=nil {
+		prior := lps.cell_probabilities[problem.steps].Map(problem.end)
+		pop.SetPrior(prior)
+		p_temp.SetPrior(prior)
+	}
+	
+	var adapt *GAAdapter
+	if params.Adaptive>0 {
+		adapt = NewGAAdapter(params)
+		pop.SetAdapter(adapt)
+		p_temp.SetAdapter(adapt)
 	}
-	true_end_1s = problem.end.CompareTo(board_empty, nil)
 	
-	checkpoints:=100
-	checkpoints=20 // TODO:: REMOVE THIS
+	checkpoints := params.Checkpoints
 	
-	iter_max  := 2000
+	iter_max  := params.IterMax
 	iter_last := 0
 	for iter:=0; iter<iter_max; iter++ {
-		// Evaluate fitness of every individual in pop
+		// Evaluate fitness of every individual in pop (simulated in batches)
+		mismatches := pop.EvaluateMismatches(problem.steps, problem.Rule(), known_mask)
 		for i, individual := range pop.individual {
-			l.current.CopyFrom(individual.start)
-			
 			mismatch_from_true_start:=-999 // NB: Don't use this in fitness calculations!!
 			if lps.is_training {
-				diff     := NewBoard_BoolPacked(board_width, board_height)
-				mismatch_from_true_start = l.current.CompareTo(problem.start, diff)
+				mismatch_from_true_start = individual.start.CompareTo(problem.start, nil)
 				
 				if i==0 { // NB: Best individual is always in [0] (forced there in GenerationAfter)
 					mismatch_from_true_start_latest  = mismatch_from_true_start
@@ -261,10 +415,8 @@ func create_solution(problem LifeProblem, lps *LifeProblemSet) *IndividualResult
 				}
 			}
 			
-			l.Iterate(problem.steps)
-			
-			// This is 'allowed' since we know the end result, and can store the diff
-			mismatch_from_true_end := l.current.CompareTo(problem.end, individual.diff)
+			// This is 'allowed' since we know the end result, and have stored the diff
+			mismatch_from_true_end := mismatches[i]
 			
 			if i==0 { // NB: Best individual is always in [0] (forced there in GenerationAfter)
 				mismatch_from_true_end_latest  = mismatch_from_true_end
@@ -274,7 +426,7 @@ func create_solution(problem LifeProblem, lps *LifeProblemSet) *IndividualResult
 			}
 			
 			// This is a lower factor pressure, but good to have too
-			count_on := individual.start.CompareTo(board_empty, nil)
+			count_on := individual.start.Popcount()
 			
 			individual.fitness = -mismatch_from_true_end  -count_on*0
 			//individual.fitness = -mismatch_from_true_end*4 -count_on*1
@@ -287,9 +439,23 @@ func create_solution(problem LifeProblem, lps *LifeProblemSet) *IndividualResult
 			iter_last=iter
 		}
 		
+		if adapt!=nil {
+			adapt.Credit(pop.individual)
+		}
+		pop.Crowd() // Children that did worse than their parents give their places back (with crowding)
+		
+		if iter % checkpoints == 0 {
+			fmt.Printf("%4d.div: Mean Hamming distance between individuals = %5.1f, distinct boards = %4d\n", iter, pop.Diversity(2000), pop.Distinct())
+		}
+		
 		best_individual = pop.BestIndividual()
 		//fmt.Printf("%4d.best: Mismatch vs true {start,end} = {???,%3d}\n", iter, best_individual.fitness)
 		//fmt.Print(best_individual.start)
+		
+		if orphans.min_errors > 0 && -best_individual.fitness <= orphans.min_errors {
+			// Only the orphans are left wrong, and they can't be fixed
+			break
+		}
 
 		if iter>0 && (iter % checkpoints == 0) {
 			difference_over_100_generations := best_individual.start.CompareTo(best_individual_start, nil)
@@ -305,10 +471,18 @@ func create_solution(problem LifeProblem, lps *LifeProblemSet) *IndividualResult
 			best_individual_start.CopyFrom(best_individual.start)
 		}
 		
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		
 		p_temp.GenerationAfter(pop)
 		pop, p_temp = p_temp, pop // Switcheroo to advance to next population
 	}
 	
+	if adapt!=nil {
+		fmt.Printf("problem[%d] (delta=%d) operator mix : %s\n", problem.id, problem.steps, adapt)
+	}
+	
 	return &IndividualResult{
 		individual : best_individual, 
 		
@@ -321,6 +495,7 @@ func create_solution(problem LifeProblem, lps *LifeProblemSet) *IndividualResult
 		true_end_1s:true_end_1s,
 		
 		iter:iter_last,
+		params:params.String(),
 	}
 }
 
@@ -359,8 +534,8 @@ func problem_worker_for_queue(worker_id int, queue chan *Work) {
 			seed := get_unprocessed_seed_from_db(id, wp.is_training)
 			
 			fmt.Printf("(%5d/%5d) Running problem[%d].steps=%d (seed=%d)\n", wp.i, wp.n, id, wp.steps, seed)
-			rand.Seed(int64(seed))
-			individual_result := create_solution(wp.lps.problem[id], wp.lps)
+			rand.Seed(int64(seed)) // For the parts (e.g. the GA) that still use the shared source
+			individual_result := solve_problem(wp.lps.problem[id], wp.lps, rand.New(rand.NewSource(int64(seed))))
 			save_solution_to_db(id, wp.steps, seed, individual_result, wp.is_training)
 		}
 	}
@@ -410,9 +585,15 @@ func solve_list_of_problems_and_write_to_db(steps int, problem_list []int, is_tr
 		ncpu = n_problems
 	}
 	runtime.GOMAXPROCS(ncpu)
+	
+	workers := ncpu
+	if s, ok := solution_solver.(MultiCoreSolver); ok && s.MultiCore() {
+		workers = 1 // The solver puts all the cores on each problem itself
+		runtime.GOMAXPROCS(runtime.NumCPU())
+	}
 
 	// spawn workers
-	for i := 0; i < ncpu; i++ {
+	for i := 0; i < workers; i++ {
 		go problem_worker_for_queue(i, queue)
 	}
 
@@ -436,7 +617,7 @@ func solve_list_of_problems_and_write_to_db(steps int, problem_list []int, is_tr
 		eta := "Unknown"
 		rate_per_1000 := 0
 		
-		completed_units := (i-ncpu) // We launched ncpu 'for free', so only have completed the reduced #
+		completed_units := (i-workers) // We launched workers 'for free', so only have completed the reduced #
 		if completed_units>0 {
 			total_duration := now_time.Sub(start_time)
 			
@@ -452,8 +633,8 @@ func solve_list_of_problems_and_write_to_db(steps int, problem_list []int, is_tr
 	}
 
 	// all work is done
-	// push ncpu*nil on the queue so that each worker will receive signal that there is no more work
-	for n := 0; n < ncpu; n++ {
+	// push workers*nil on the queue so that each worker will receive signal that there is no more work
+	for n := 0; n < workers; n++ {
 		queue <- nil
 	}
 }
@@ -0,0 +1,208 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"math/bits"
+)
+
+// IncrementalBoard : A start board whose end board (and where that's wrong) is kept up to date as it changes.
+// Changes are made to trial, and then evaluated and committed or reverted.  A change only touches a few rows
+// of the start, which can only change the end rows within steps of them, so only those are re-simulated
+// and re-counted.  This is what the local searches (Annealer, WalkSearch) have in common.
+
+type IncrementalBoard struct {
+	steps int
+	rule  *Rule
+
+	target    *Board_BoolPacked
+	known     *Board_BoolPacked // nil if every cell counts
+	unfixable *Board_BoolPacked // nil if there aren't any
+	floor     int               // Mismatches that can't be got rid of (so stop there)
+
+	start, trial *Board_BoolPacked // trial is the same as start, except while a change is tried
+	end, diff    *Board_BoolPacked // start after steps, and where it's wrong
+	chase        *Board_BoolPacked // diff without the unfixable cells
+	row_mismatch []int
+	mismatch     int
+
+	windows map[int]*BoardIterator // By height, for re-simulating a band of rows
+}
+
+// A change to trial : Start rows y0..y1 differ, and end rows r0..r1 are in window (offset by w0)
+type band_change struct {
+	y0, y1, r0, r1 int
+	window         *Board_BoolPacked
+	w0             int
+	delta          int // Change in mismatch
+}
+
+func NewIncrementalBoard(target *Board_BoolPacked, steps int, rule *Rule) *IncrementalBoard {
+	b := &IncrementalBoard{
+		steps:  steps,
+		rule:   rule,
+		target: target,
+		known:  target.KnownMask(steps),
+
+		start:        NewBoard_BoolPacked(target.w, target.h),
+		trial:        NewBoard_BoolPacked(target.w, target.h),
+		end:          NewBoard_BoolPacked(target.w, target.h),
+		diff:         NewBoard_BoolPacked(target.w, target.h),
+		chase:        NewBoard_BoolPacked(target.w, target.h),
+		row_mismatch: make([]int, target.h),
+		windows:      make(map[int]*BoardIterator),
+	}
+	b.Reset(target)
+	return b
+}
+
+// Reset makes start the current board (and works out everything from scratch)
+func (b *IncrementalBoard) Reset(start *Board_BoolPacked) {
+	b.start.CopyFrom(start)
+	b.trial.CopyFrom(start)
+	l := NewBoardIterator(start.w, start.h)
+	l.rule = b.rule
+	l.current.CopyFrom(start)
+	l.Iterate(b.steps)
+	b.end.CopyFrom(l.current)
+	b.mismatch = 0
+	for y := 0; y < b.target.h; y++ {
+		b.row_mismatch[y] = b.count_row(b.end.row(y+1), y)
+		b.mismatch += b.row_mismatch[y]
+	}
+	b.end.CompareTo_Masked(b.target, b.diff, b.known)
+}
+
+// Chase is where the end board is wrong, leaving out the errors that can't be fixed
+func (b *IncrementalBoard) Chase() *Board_BoolPacked {
+	if b.unfixable == nil {
+		return b.diff
+	}
+	return b.chase.AndNot(b.diff, b.unfixable)
+}
+
+// The diff of row y of the end board, if it were end_row
+func (b *IncrementalBoard) diff_word(end_row []uint64, y, k int) uint64 {
+	match := end_row[k] ^ b.target.row(y + 1)[k]
+	if b.known != nil {
+		match &= b.known.row(y + 1)[k]
+	}
+	return match
+}
+
+// Mismatches in row y of the end board, if it were end_row
+func (b *IncrementalBoard) count_row(end_row []uint64, y int) int {
+	n := 0
+	for k := range end_row {
+		n += bits.OnesCount64(b.diff_word(end_row, y, k))
+	}
+	return n
+}
+
+// The rows in which trial differs from start (ok=false if it doesn't)
+func (b *IncrementalBoard) changed_rows() (y0, y1 int, ok bool) {
+	y0, y1 = b.start.h, -1
+	for y := 0; y < b.start.h; y++ {
+		s, t := b.start.row(y+1), b.trial.row(y+1)
+		for k := range s {
+			if s[k] != t[k] {
+				if y < y0 {
+					y0 = y
+				}
+				y1 = y
+				break
+			}
+		}
+	}
+	return y0, y1, y1 >= 0
+}
+
+// Re-simulate trial for end rows r0..r1 (which depend only on start rows within steps of them)
+// Returns the board that holds those rows, offset by w0 (it's reused by the next call)
+func (b *IncrementalBoard) simulate_band(r0, r1 int) (window *Board_BoolPacked, w0 int) {
+	w0, w1 := r0-b.steps, r1+b.steps
+	boundary := Boundary_Dead
+	if b.start.boundary == Boundary_Torus { // Rows wrap around, so just do the whole board
+		w0, w1, boundary = 0, b.start.h-1, Boundary_Torus
+	}
+	if w0 < 0 {
+		w0 = 0
+	}
+	if w1 > b.start.h-1 {
+		w1 = b.start.h - 1
+	}
+	h := w1 - w0 + 1
+	l, ok := b.windows[h]
+	if !ok {
+		l = NewBoardIterator(b.start.w, h)
+		l.rule = b.rule
+		b.windows[h] = l
+	}
+	// Cut rows see dead cells beyond them, but that only reaches steps rows in, i.e. not as far as r0..r1
+	l.current.boundary = boundary
+	for y := w0; y <= w1; y++ {
+		copy(l.current.row(y-w0+1), b.trial.row(y+1))
+	}
+	l.Iterate(b.steps)
+	return l.current, w0
+}
+
+// evaluate works out what the change made to trial does to the end board (ok=false if there's no change)
+func (b *IncrementalBoard) evaluate() (c band_change, ok bool) {
+	c.y0, c.y1, ok = b.changed_rows()
+	if !ok {
+		return c, false
+	}
+	c.r0, c.r1 = c.y0-b.steps, c.y1+b.steps
+	if b.start.boundary == Boundary_Torus {
+		c.r0, c.r1 = 0, b.start.h-1
+	}
+	if c.r0 < 0 {
+		c.r0 = 0
+	}
+	if c.r1 > b.start.h-1 {
+		c.r1 = b.start.h - 1
+	}
+	c.window, c.w0 = b.simulate_band(c.r0, c.r1)
+	for y := c.r0; y <= c.r1; y++ {
+		c.delta += b.count_row(c.window.row(y-c.w0+1), y) - b.row_mismatch[y]
+	}
+	return c, true
+}
+
+// make_break counts the end cells the change would put right (made) and put wrong (broken)
+func (b *IncrementalBoard) make_break(c band_change) (made, broken int) {
+	for y := c.r0; y <= c.r1; y++ {
+		window_row, diff_row := c.window.row(y-c.w0+1), b.diff.row(y+1)
+		for k := range diff_row {
+			now := b.diff_word(window_row, y, k)
+			made += bits.OnesCount64(diff_row[k] &^ now)
+			broken += bits.OnesCount64(now &^ diff_row[k])
+		}
+	}
+	return made, broken
+}
+
+// commit keeps the change (c must come from the latest evaluate)
+func (b *IncrementalBoard) commit(c band_change) {
+	for y := c.y0; y <= c.y1; y++ {
+		copy(b.start.row(y+1), b.trial.row(y+1))
+	}
+	for y := c.r0; y <= c.r1; y++ {
+		copy(b.end.row(y+1), c.window.row(y-c.w0+1))
+		b.row_mismatch[y] = b.count_row(b.end.row(y+1), y)
+		diff_row, end_row := b.diff.row(y+1), b.end.row(y+1)
+		for k := range diff_row {
+			diff_row[k] = b.diff_word(end_row, y, k)
+		}
+	}
+	b.mismatch += c.delta
+}
+
+// revert puts trial back the way start is
+func (b *IncrementalBoard) revert(c band_change) {
+	for y := c.y0; y <= c.y1; y++ {
+		copy(b.trial.row(y+1), b.start.row(y+1))
+	}
+}
@@ -0,0 +1,284 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Island-model GA : Several Populations for the same problem evolve on their own goroutines, and every
+// migrate_every generations copies of each island's best individuals replace the worst ones on the islands
+// it's connected to.  This puts all the cores on one problem, which suits the hard (delta=4/5) problems
+// better than lots of shallow searches side by side.  Each island has the GA parameters' pop_size, and runs
+// for up to iter_max generations.
+
+type IslandTopology int
+
+const (
+	Island_Ring IslandTopology = iota // Island i sends its migrants to island i+1
+	Island_Full                       // Every island sends its migrants to every other island
+)
+
+func (t IslandTopology) String() string {
+	if t == Island_Full {
+		return "full"
+	}
+	return "ring"
+}
+
+func ParseIslandTopology(name string) (IslandTopology, bool) {
+	switch name {
+	case "ring":
+		return Island_Ring, true
+	case "full":
+		return Island_Full, true
+	}
+	return Island_Ring, false
+}
+
+type IslandSettings struct {
+	islands       int // 0 = one per cpu
+	migrate_every int // Generations between migrations (0 = never migrate)
+	migrants      int // From each island to each of its neighbours
+	topology      IslandTopology
+	stall         int // Migrations without a better best before giving up (0 = never)
+}
+
+// What Solver_Islands uses (-type=islands on the run command, see -islands, -topology, -migrate, -migrants and -stall)
+var island_settings = IslandSettings{
+	migrate_every: 20, migrants: 5, topology: Island_Ring, stall: 5,
+}
+
+// What an island run saves as its params : The GA parameters (pop_size is for each island), and the island settings
+type island_run_params struct {
+	GAParams
+	Islands      int    `json:"islands"`
+	Topology     string `json:"topology"`
+	MigrateEvery int    `json:"migrate_every"`
+	Migrants     int    `json:"migrants"`
+	Stall        int    `json:"stall"`
+}
+
+func (p island_run_params) String() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+type island struct {
+	pop, p_temp *Population
+	adapt       *GAAdapter // Each island adapts on its own (nil unless the 'adaptive' GA parameter is set)
+}
+
+// evolve runs generations on the island, and leaves every individual with its fitness (and diff) up to date
+func (is *island) evolve(problem LifeProblem, known_mask *Board_BoolPacked, generations int) {
+	for g := 0; g <= generations; g++ {
+		if g > 0 {
+			is.p_temp.GenerationAfter(is.pop)
+			is.pop, is.p_temp = is.p_temp, is.pop // Switcheroo to advance to next population
+		}
+		mismatches := is.pop.EvaluateMismatches(problem.steps, problem.Rule(), known_mask)
+		for i, individual := range is.pop.individual {
+			individual.fitness = -mismatches[i]
+		}
+		if g > 0 { // Not straight after a migration : That generation has been seen already, and the arrivals have no parents here
+			if is.adapt != nil {
+				is.adapt.Credit(is.pop.individual)
+			}
+			is.pop.Crowd()
+		}
+	}
+}
+
+// The island's individuals, best first
+func (is *island) ranked() []*Individual {
+	ranked := append([]*Individual(nil), is.pop.individual...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].fitness > ranked[j].fitness })
+	return ranked
+}
+
+// Which islands island i sends its migrants to
+func (s IslandSettings) neighbours(i, n int) []int {
+	if s.topology == Island_Full {
+		others := []int{}
+		for j := 0; j < n; j++ {
+			if j != i {
+				others = append(others, j)
+			}
+		}
+		return others
+	}
+	return []int{(i + 1) % n}
+}
+
+// migrate copies each island's best individuals over the worst ones of its neighbours
+func (s IslandSettings) migrate(islands []*island) {
+	n := len(islands)
+	if n < 2 || s.migrants <= 0 {
+		return
+	}
+	// Take copies of everyone's emigrants first, so that nobody passes on an individual that just arrived
+	emigrants := make([][]*Individual, n)
+	for i, is := range islands {
+		ranked := is.ranked()
+		if len(ranked) > s.migrants {
+			ranked = ranked[:s.migrants]
+		}
+		for _, individual := range ranked {
+			copied := &Individual{
+				start:   NewBoard_BoolPacked(individual.start.w, individual.start.h),
+				diff:    NewBoard_BoolPacked(individual.diff.w, individual.diff.h),
+				fitness: individual.fitness,
+			}
+			copied.start.CopyFrom(individual.start)
+			copied.diff.CopyFrom(individual.diff)
+			emigrants[i] = append(emigrants[i], copied)
+		}
+	}
+	arrivals := make([][]*Individual, n)
+	for i := range islands {
+		for _, j := range s.neighbours(i, n) {
+			arrivals[j] = append(arrivals[j], emigrants[i]...)
+		}
+	}
+	for j, is := range islands {
+		ranked := is.ranked()
+		for k, arrival := range arrivals[j] {
+			if k >= len(ranked)/2 { // Don't swamp the island
+				break
+			}
+			worst := ranked[len(ranked)-1-k]
+			worst.start.CopyFrom(arrival.start)
+			worst.diff.CopyFrom(arrival.diff)
+			worst.fitness = arrival.fitness
+			worst.op, worst.radius, worst.parent, worst.parent_fitness = Op_None, 0, nil, 0 // Not made here
+		}
+	}
+}
+
+type Solver_Islands struct{}
+
+// MultiCore : The islands use all the cores, so problems should be handed out one at a time
+func (Solver_Islands) MultiCore() bool {
+	return true
+}
+
+// Each island has its own random (seeded from random), so runs repeat whatever order the goroutines go in
+func (Solver_Islands) Solve(problem LifeProblem, lps *LifeProblemSet, budget time.Duration, random *rand.Rand) *IndividualResult {
+	s := island_settings
+	n := s.islands
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	deadline := budget_deadline(budget)
+
+	// For Boundary_Unknown, don't penalize cells that the outside could have reached
+	known_mask := problem.end.KnownMask(problem.steps)
+
+	orphans := problem_orphans(problem, known_mask)
+	var prior *ProbabilityMap
+	if problem.steps < len(lps.cell_probabilities) && lps.cell_probabilities[problem.steps] != nil {
+		prior = lps.cell_probabilities[problem.steps].Map(problem.end)
+	}
+
+	params := ga_params(problem.steps)
+	islands := make([]*island, n)
+	for i := range islands {
+		is := &island{
+			pop:    NewPopulation(params.PopSize, params, problem.end, &lps.transition_collection[problem.steps]),
+			p_temp: NewPopulation(params.PopSize, params, problem.end, &lps.transition_collection[problem.steps]),
+		}
+		for _, individual := range is.pop.individual {
+			// NB:  We can only work from the problem.end
+			individual.start.CopyFrom(problem.end)
+		}
+		island_random := rand.New(rand.NewSource(random.Int63()))
+		for _, pop := range []*Population{is.pop, is.p_temp} {
+			pop.SetRandom(island_random)
+			if orphans.orphans > 0 {
+				pop.SetUnfixable(orphans.unfixable)
+			}
+			if prior != nil {
+				pop.SetPrior(prior)
+			}
+		}
+		if params.Adaptive > 0 {
+			is.adapt = NewGAAdapter(params)
+			is.pop.SetAdapter(is.adapt)
+			is.p_temp.SetAdapter(is.adapt)
+		}
+		islands[i] = is
+	}
+
+	best := &Individual{start: NewBoard_BoolPacked(problem.end.w, problem.end.h)}
+	best_mismatch, mismatch_from_true_end_initial := -1, -1
+	epoch_length := s.migrate_every
+	if epoch_length <= 0 { // No migrations, but still stop now and again to check on progress
+		epoch_length = params.Checkpoints
+	}
+	generations, stalled := 0, 0
+	for epoch := 0; epoch == 0 || generations < params.IterMax; epoch = epoch_length { // The first is just to evaluate the starting populations
+		if generations+epoch > params.IterMax {
+			epoch = params.IterMax - generations
+		}
+		var wg sync.WaitGroup
+		for _, is := range islands {
+			wg.Add(1)
+			go func(is *island) {
+				defer wg.Done()
+				is.evolve(problem, known_mask, epoch)
+			}(is)
+		}
+		wg.Wait()
+		generations += epoch
+
+		island_best := make([]int, n)
+		improved := false
+		for i, is := range islands {
+			top := is.pop.BestIndividual()
+			island_best[i] = -top.fitness
+			if best_mismatch < 0 || -top.fitness < best_mismatch {
+				best.start.CopyFrom(top.start)
+				best_mismatch, improved = -top.fitness, true
+			}
+		}
+		if mismatch_from_true_end_initial < 0 {
+			mismatch_from_true_end_initial = best_mismatch
+		}
+		fmt.Printf("%4d : Mismatch vs end on %d islands (%s) = %v, best %3d\n", generations, n, s.topology, island_best, best_mismatch)
+
+		if improved {
+			stalled = 0
+		} else {
+			stalled++
+		}
+		if best_mismatch <= orphans.min_errors || (s.stall > 0 && stalled >= s.stall) {
+			break
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		if s.migrate_every > 0 {
+			s.migrate(islands)
+		}
+	}
+
+	for i, is := range islands {
+		if is.adapt != nil {
+			fmt.Printf("problem[%d] (delta=%d) island %d operator mix : %s\n", problem.id, problem.steps, i, is.adapt)
+		}
+	}
+
+	result := finish_result(problem, lps, best.start, mismatch_from_true_end_initial)
+	result.iter = generations
+	result.params = island_run_params{
+		GAParams: params, Islands: n, Topology: s.topology.String(), MigrateEvery: s.migrate_every, Migrants: s.migrants, Stall: s.stall,
+	}.String()
+	return result
+}
@@ -0,0 +1,317 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Splitting a board into separate objects (clusters of live cells), and naming them
+// from a catalog of the common still lifes, oscillators and spaceships
+
+type ObjectKind int
+
+const (
+	Object_Other ObjectKind = iota
+	Object_StillLife
+	Object_Oscillator
+	Object_Spaceship
+)
+
+func (k ObjectKind) String() string {
+	switch k {
+	case Object_StillLife:
+		return "still-life"
+	case Object_Oscillator:
+		return "oscillator"
+	case Object_Spaceship:
+		return "spaceship"
+	}
+	return "other"
+}
+
+type Object struct {
+	x, y       int               // Top-left of the bounding box on the original board
+	cells      *Board_BoolPacked // Just the bounding box
+	population int
+	kind       ObjectKind
+	name       string
+	period     int
+	dx, dy     int // Movement per period (spaceships only)
+}
+
+func (o *Object) String() string {
+	s := o.name
+	if o.kind == Object_Spaceship {
+		s += fmt.Sprintf(" heading %s", compass_direction(o.dx, o.dy))
+	}
+	return s
+}
+
+func compass_direction(dx, dy int) string {
+	d := ""
+	if dy < 0 {
+		d += "N"
+	}
+	if dy > 0 {
+		d += "S"
+	}
+	if dx > 0 {
+		d += "E"
+	}
+	if dx < 0 {
+		d += "W"
+	}
+	return d
+}
+
+// Crop copies out the (inclusive) rectangle, as a board with dead edges
+func (f *Board_BoolPacked) Crop(x_min, y_min, x_max, y_max int) *Board_BoolPacked {
+	c := NewBoard_BoolPacked(x_max-x_min+1, y_max-y_min+1)
+	c.boundary = Boundary_Dead
+	for y := y_min; y <= y_max; y++ {
+		for x := x_min; x <= x_max; x++ {
+			if f.isSet(x, y) {
+				c.Set(x-x_min, y-y_min, true)
+			}
+		}
+	}
+	return c
+}
+
+// Components splits the board into clusters : distance=1 gives the usual 8-connected objects,
+// larger distances join up cells with gaps of up to distance-1 dead cells between them
+// (e.g. the quarters of a pulsar need distance=2)
+func (f *Board_BoolPacked) Components(distance int) []*Object {
+	objects := []*Object{}
+	remaining := NewBoard_BoolPacked(f.w, f.h)
+	remaining.CopyFrom(f)
+	remaining.boundary = Boundary_Dead // Objects aren't joined up around a torus (their bounding boxes would be wrong)
+
+	component := NewBoard_BoolPacked(f.w, f.h)
+	grown := NewBoard_BoolPacked(f.w, f.h)
+	for remaining.Popcount() > 0 {
+		// Seed with the first remaining cell, and grow it until it stops changing
+		for i := range component.s {
+			component.s[i] = 0
+		}
+		component.boundary = Boundary_Dead
+		x, y, _, _, _ := remaining.BoundingBox()
+		for !remaining.isSet(x, y) { // Top row is y, but x is only the leftmost column overall
+			x++
+		}
+		component.Set(x, y, true)
+		for {
+			before := component.Popcount()
+			grown.Dilate(component, distance)
+			component.And(grown, remaining)
+			if component.Popcount() == before {
+				break
+			}
+		}
+		remaining.AndNot(remaining, component)
+
+		x_min, y_min, x_max, y_max, _ := component.BoundingBox()
+		objects = append(objects, &Object{
+			x: x_min, y: y_min,
+			cells:      component.Crop(x_min, y_min, x_max, y_max),
+			population: component.Popcount(),
+			name:       "other",
+		})
+	}
+	return objects
+}
+
+// Put the cells in the middle of an empty board with room to move, and run them forwards
+func run_isolated(cells *Board_BoolPacked, margin, steps int, rule *Rule) *BoardIterator {
+	l := NewBoardIterator(cells.w+2*margin, cells.h+2*margin)
+	l.rule = rule
+	l.current.boundary = Boundary_Dead
+	for y := 0; y < cells.h; y++ {
+		for x := 0; x < cells.w; x++ {
+			if cells.isSet(x, y) {
+				l.current.Set(x+margin, y+margin, true)
+			}
+		}
+	}
+	l.Iterate(steps)
+	return l
+}
+
+type catalog_entry struct {
+	name   string
+	kind   ObjectKind
+	period int
+	cells  string // Plaintext format
+}
+
+// All of these are for Conway's rule (B3/S23)
+var object_catalog_source = []catalog_entry{
+	{"block", Object_StillLife, 1, "OO\nOO"},
+	{"beehive", Object_StillLife, 1, ".OO.\nO..O\n.OO."},
+	{"loaf", Object_StillLife, 1, ".OO.\nO..O\n.O.O\n..O."},
+	{"boat", Object_StillLife, 1, "OO.\nO.O\n.O."},
+	{"ship", Object_StillLife, 1, "OO.\nO.O\n.OO"},
+	{"tub", Object_StillLife, 1, ".O.\nO.O\n.O."},
+	{"pond", Object_StillLife, 1, ".OO.\nO..O\nO..O\n.OO."},
+	{"barge", Object_StillLife, 1, ".O..\nO.O.\n.O.O\n..O."},
+	{"long boat", Object_StillLife, 1, "OO..\nO.O.\n.O.O\n..O."},
+	{"snake", Object_StillLife, 1, "OO.O\nO.OO"},
+	{"eater", Object_StillLife, 1, "OO..\nO.O.\n..O.\n..OO"},
+	{"aircraft carrier", Object_StillLife, 1, "OO..\nO..O\n..OO"},
+	{"mango", Object_StillLife, 1, ".OO..\nO..O.\n.O..O\n..OO."},
+
+	{"blinker", Object_Oscillator, 2, "OOO"},
+	{"toad", Object_Oscillator, 2, ".OOO\nOOO."},
+	{"beacon", Object_Oscillator, 2, "OO..\nOO..\n..OO\n..OO"},
+	{"clock", Object_Oscillator, 2, "..O.\nO.O.\n.O.O\n.O.."},
+	{"pulsar", Object_Oscillator, 3, strings.Join([]string{
+		"..OOO...OOO..",
+		".............",
+		"O....O.O....O",
+		"O....O.O....O",
+		"O....O.O....O",
+		"..OOO...OOO..",
+		".............",
+		"..OOO...OOO..",
+		"O....O.O....O",
+		"O....O.O....O",
+		"O....O.O....O",
+		".............",
+		"..OOO...OOO..",
+	}, "\n")},
+	{"pentadecathlon", Object_Oscillator, 15, "..O....O..\nOO.OOOO.OO\n..O....O.."},
+
+	{"glider", Object_Spaceship, 4, ".O.\n..O\nOOO"},
+	{"LWSS", Object_Spaceship, 4, ".O..O\nO....\nO...O\nOOOO."},
+	{"MWSS", Object_Spaceship, 4, "...O..\n.O...O\nO.....\nO....O\nOOOOO."},
+	{"HWSS", Object_Spaceship, 4, "...OO..\n.O....O\nO......\nO.....O\nOOOOOO."},
+}
+
+// Canonical (over rotations and reflections) binary form of every phase of every catalog entry
+var object_catalog map[string]*catalog_entry
+
+func object_catalog_key(cells *Board_BoolPacked) string {
+	canonical, _ := cells.Canonical()
+	return string(canonical.toBinary())
+}
+
+func build_object_catalog() {
+	object_catalog = make(map[string]*catalog_entry)
+	for i := range object_catalog_source {
+		entry := &object_catalog_source[i]
+		pattern, err := ParseCells(entry.cells)
+		if err != nil {
+			panic(fmt.Sprintf("Object catalog '%s' : %v", entry.name, err))
+		}
+		l := run_isolated(pattern, entry.period, 0, Rule_Conway)
+		for phase := 0; phase < entry.period; phase++ {
+			x_min, y_min, x_max, y_max, _ := l.current.BoundingBox()
+			object_catalog[object_catalog_key(l.current.Crop(x_min, y_min, x_max, y_max))] = entry
+			l.Iterate(1)
+		}
+	}
+}
+
+// Classify names the object from the catalog (only for Conway's rule), and otherwise
+// just works out whether it's a still life or oscillator on its own
+func (o *Object) Classify(rule *Rule) {
+	if object_catalog == nil {
+		build_object_catalog()
+	}
+	if entry, ok := object_catalog[object_catalog_key(o.cells)]; ok && rule.IsConway() {
+		o.name, o.kind, o.period = entry.name, entry.kind, entry.period
+		if o.kind == Object_Spaceship { // Which way is it going?
+			l := run_isolated(o.cells, o.period, o.period, rule)
+			x_min, y_min, _, _, _ := l.current.BoundingBox()
+			o.dx, o.dy = x_min-o.period, y_min-o.period
+		}
+		return
+	}
+
+	const max_period = 30
+	l := run_isolated(o.cells, max_period/2, 0, rule)
+	report := l.Analyze(max_period)
+	if report.cycle_start == 0 && report.fate == Fate_StillLife {
+		o.kind, o.period = Object_StillLife, 1
+		o.name = fmt.Sprintf("still-life/%d", o.population)
+	} else if report.cycle_start == 0 && report.fate == Fate_Oscillator {
+		o.kind, o.period = Object_Oscillator, report.period
+		o.name = fmt.Sprintf("p%d-oscillator/%d", o.period, o.population)
+	} else {
+		o.name = fmt.Sprintf("other/%d", o.population)
+	}
+}
+
+// Census is the list of (classified) objects on a board
+type Census struct {
+	objects []*Object
+}
+
+func (f *Board_BoolPacked) Census(distance int, rule *Rule) *Census {
+	c := &Census{objects: f.Components(distance)}
+	for _, o := range c.objects {
+		o.Classify(rule)
+	}
+	return c
+}
+
+// Tally gives the count of each object name (spaceships are counted together, whatever their heading)
+func (c *Census) Tally() map[string]int {
+	tally := make(map[string]int)
+	for _, o := range c.objects {
+		tally[o.name]++
+	}
+	return tally
+}
+
+// String is a one-line summary : "2 block, 1 blinker, 1 glider heading SE, 1 other/7"
+func (c *Census) String() string {
+	if len(c.objects) == 0 {
+		return "empty"
+	}
+	counts := make(map[string]int)
+	names := []string{}
+	for _, o := range c.objects {
+		s := o.String()
+		if counts[s] == 0 {
+			names = append(names, s)
+		}
+		counts[s]++
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%d %s", counts[name], name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SaveCensusCSV writes one line per object, for the problems given (in id order)
+func SaveCensusCSV(filename string, census map[int]*Census) {
+	file, err := os.Create(filename)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	defer file.Close()
+
+	file.WriteString("id,x,y,w,h,population,kind,name,period,dx,dy\n")
+
+	ids := []int{}
+	for id := range census {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		for _, o := range census[id].objects {
+			file.WriteString(fmt.Sprintf("%d,%d,%d,%d,%d,%d,%s,%s,%d,%d,%d\n",
+				id, o.x, o.y, o.cells.w, o.cells.h, o.population, o.kind, o.name, o.period, o.dx, o.dy))
+		}
+	}
+}
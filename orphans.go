@@ -0,0 +1,235 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Garden-of-Eden detection : Some end patterns can't be produced by any start board.
+// The 5x5 neighbourhood of a target cell is decided by the 7x7 block of the board before, so for each
+// neighbourhood we search (row by row) for a 7x7 predecessor.  A neighbourhood without one is an 'orphan',
+// and whatever the GA does, at least one of its cells must end up wrong.
+// Since the last step has to produce the target, this holds however many steps there are.
+
+// Exhaustive table for one rule : For every pair of 7-cell start rows (a,b), the third rows c, grouped by
+// the 5 cells of the middle row that (a,b,c) produces
+type predecessor_table struct {
+	offsets []uint16 // [ab*33 + out] is where that group starts in rows (and [ab*33+32] is the end)
+	rows    []uint8  // [ab*128 + i]
+	cache   map[orphan_key]bool
+}
+
+// The most neighbourhoods a table remembers the answer for (it starts again when it's full)
+const orphan_cache_max = 1 << 20
+
+// One scan's search space : The tables are shared, but each scan has its own record of failed states
+type orphan_search struct {
+	t      *predecessor_table
+	failed []uint32 // Search states known to fail, marked with the epoch of the search
+	epoch  uint32
+}
+
+type orphan_key struct {
+	end, care [5]uint8 // Target rows, and which of their cells matter
+	forced    [7]uint8 // Start cells that have to be dead (beyond a Boundary_Dead edge)
+}
+
+var predecessor_tables = make(map[string]*predecessor_table) // by rule.String()
+var predecessor_tables_lock sync.Mutex                       // The tables and their caches are shared by all the workers (rows and offsets are read-only once made)
+
+func strip_output(a, b, c uint, rule *Rule) uint {
+	out := uint(0)
+	for x := uint(0); x < 5; x++ {
+		window := ((a>>x)&7)<<6 | ((b>>x)&7)<<3 | ((c >> x) & 7)
+		if rule.next[window] {
+			out |= 1 << x
+		}
+	}
+	return out
+}
+
+func get_predecessor_table(rule *Rule) *predecessor_table {
+	if t, ok := predecessor_tables[rule.String()]; ok {
+		return t
+	}
+	t := &predecessor_table{
+		offsets: make([]uint16, 128*128*33),
+		rows:    make([]uint8, 128*128*128),
+		cache:   make(map[orphan_key]bool),
+	}
+	for ab := uint(0); ab < 128*128; ab++ {
+		a, b := ab>>7, ab&127
+		var outs [128]uint
+		var count [33]uint16
+		for c := uint(0); c < 128; c++ {
+			outs[c] = strip_output(a, b, c, rule)
+			count[outs[c]+1]++
+		}
+		for out := 1; out <= 32; out++ { // Counting sort
+			count[out] += count[out-1]
+		}
+		copy(t.offsets[ab*33:], count[:])
+		for c := uint(0); c < 128; c++ {
+			t.rows[ab*128+uint(count[outs[c]])] = uint8(c)
+			count[outs[c]]++
+		}
+	}
+	predecessor_tables[rule.String()] = t
+	return t
+}
+
+// Is there a 7x7 start block that gives the end rows (where they're cared about)?
+// Depth-first, a start row at a time, remembering the (row, last two start rows) states that failed
+func (s *orphan_search) has_predecessor(k orphan_key) bool {
+	t := s.t
+	predecessor_tables_lock.Lock()
+	cached, ok := t.cache[k]
+	predecessor_tables_lock.Unlock()
+	if ok {
+		return cached
+	}
+	if s.failed == nil {
+		s.failed = make([]uint32, 5*128*128)
+	}
+	s.epoch++ // Rather than clearing s.failed
+
+	var outs [5][]int
+	for r := 0; r < 5; r++ {
+		for out := 0; out < 32; out++ {
+			if (uint8(out)^k.end[r])&k.care[r] == 0 {
+				outs[r] = append(outs[r], out)
+			}
+		}
+	}
+
+	var search func(r, ab int) bool
+	search = func(r, ab int) bool {
+		if r == 5 {
+			return true
+		}
+		if s.failed[r*128*128+ab] == s.epoch {
+			return false
+		}
+		for _, out := range outs[r] {
+			for _, c := range t.rows[ab*128+int(t.offsets[ab*33+out]) : ab*128+int(t.offsets[ab*33+out+1])] {
+				if c&k.forced[r+2] == 0 && search(r+1, (ab&127)<<7|int(c)) {
+					return true
+				}
+			}
+		}
+		s.failed[r*128*128+ab] = s.epoch
+		return false
+	}
+
+	result := false
+	for ab := 0; ab < 128*128 && !result; ab++ {
+		if uint8(ab>>7)&k.forced[0] == 0 && uint8(ab&127)&k.forced[1] == 0 {
+			result = search(0, ab)
+		}
+	}
+	predecessor_tables_lock.Lock()
+	if len(t.cache) >= orphan_cache_max {
+		t.cache = make(map[orphan_key]bool)
+	}
+	t.cache[k] = result
+	predecessor_tables_lock.Unlock()
+	return result
+}
+
+// As wrap, but also saying whether (x,y) is on the board at all
+func (f *Board_BoolPacked) locate(x, y int) (int, int, bool) {
+	x, y = f.wrap(x, y)
+	return x, y, x >= 0 && x < f.w && y >= 0 && y < f.h
+}
+
+// Neighbourhood of (cx,cy) on f : Cells off the board don't matter (unless it's a torus), and nor
+// do cells outside care (if that's given, e.g. the KnownMask)
+func (f *Board_BoolPacked) orphan_key(cx, cy int, care *Board_BoolPacked) orphan_key {
+	var k orphan_key
+	for j := 0; j < 5; j++ {
+		for i := 0; i < 5; i++ {
+			x, y, on_board := f.locate(cx-2+i, cy-2+j)
+			if on_board && (care == nil || care.isSet(x, y)) {
+				k.care[j] |= 1 << uint(i)
+				if f.isSet(x, y) {
+					k.end[j] |= 1 << uint(i)
+				}
+			}
+		}
+	}
+	if f.boundary == Boundary_Dead {
+		for j := 0; j < 7; j++ {
+			for i := 0; i < 7; i++ {
+				if _, _, on_board := f.locate(cx-3+i, cy-3+j); !on_board {
+					k.forced[j] |= 1 << uint(i)
+				}
+			}
+		}
+	}
+	return k
+}
+
+type OrphanReport struct {
+	centres    *Board_BoolPacked // Centres of the orphan neighbourhoods
+	unfixable  *Board_BoolPacked // Cells that would rescue an orphan neighbourhood if they were different
+	orphans    int
+	min_errors int // Lower bound on the mismatch of any solution (from non-overlapping orphans)
+}
+
+func (r *OrphanReport) String() string {
+	return fmt.Sprintf("%d orphan neighbourhoods, at least %d cells can't be matched", r.orphans, r.min_errors)
+}
+
+// FindOrphans checks every 5x5 neighbourhood of the target f (only counting the cells in care, if given)
+func (f *Board_BoolPacked) FindOrphans(rule *Rule, care *Board_BoolPacked) *OrphanReport {
+	predecessor_tables_lock.Lock()
+	search := &orphan_search{t: get_predecessor_table(rule)}
+	predecessor_tables_lock.Unlock()
+	r := &OrphanReport{
+		centres:   NewBoard_BoolPacked(f.w, f.h),
+		unfixable: NewBoard_BoolPacked(f.w, f.h),
+	}
+	counted := NewBoard_BoolPacked(f.w, f.h) // Cells of the orphans counted in min_errors so far
+	for cy := 0; cy < f.h; cy++ {
+		for cx := 0; cx < f.w; cx++ {
+			k := f.orphan_key(cx, cy, care)
+			if search.has_predecessor(k) {
+				continue
+			}
+			r.orphans++
+			r.centres.Set(cx, cy, true)
+
+			overlaps := false
+			for j := 0; j < 5; j++ {
+				for i := 0; i < 5; i++ {
+					x, y, on_board := f.locate(cx-2+i, cy-2+j)
+					if on_board && counted.isSet(x, y) {
+						overlaps = true
+					}
+					if k.care[j]&(1<<uint(i)) == 0 {
+						continue
+					}
+					relaxed := k // Would this neighbourhood be OK if this cell didn't matter?
+					relaxed.care[j] &^= 1 << uint(i)
+					if search.has_predecessor(relaxed) {
+						r.unfixable.Set(x, y, true)
+					}
+				}
+			}
+			if !overlaps {
+				r.min_errors++
+				for j := 0; j < 5; j++ {
+					for i := 0; i < 5; i++ {
+						if x, y, on_board := f.locate(cx-2+i, cy-2+j); on_board {
+							counted.Set(x, y, true)
+						}
+					}
+				}
+			}
+		}
+	}
+	return r
+}
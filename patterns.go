@@ -0,0 +1,339 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Pattern files, as used by the common Life editors and pattern collections
+
+// RLE (Run Length Encoded) : See http://www.conwaylife.com/wiki/RLE
+
+// ParseRLE creates a board of the size given in the header line "x = m, y = n, rule = B3/S23"
+// The rule is optional (defaulting to the current rule), and may have a ":T<w>,<h>" torus suffix
+func ParseRLE(text string) (*Board_BoolPacked, *Rule, error) {
+	var f *Board_BoolPacked
+	rule := board_rule
+	boundary := board_boundary
+
+	x, y, count := 0, 0, 0
+	for line_number, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue // Comments (#N name, #C comment, #O author, etc)
+		}
+
+		if f == nil { // First non-comment line is the header
+			w, h := -1, -1
+			header := line
+			if i := strings.Index(header, "rule"); i >= 0 { // The rule may itself contain ',' (e.g. "B3/S23:T20,20")
+				value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(header[i+len("rule"):]), "="))
+				header = header[:i]
+				if i := strings.Index(value, ":"); i >= 0 {
+					if strings.HasPrefix(strings.ToUpper(value[i+1:]), "T") {
+						boundary = Boundary_Torus
+					}
+					value = value[:i]
+				}
+				r, err := ParseRule(value)
+				if err != nil {
+					return nil, nil, err
+				}
+				rule = r
+			}
+			for _, item := range strings.Split(header, ",") {
+				if strings.TrimSpace(item) == "" {
+					continue
+				}
+				kv := strings.SplitN(item, "=", 2)
+				if len(kv) != 2 {
+					return nil, nil, fmt.Errorf("RLE header '%s' not understood", line)
+				}
+				switch strings.TrimSpace(kv[0]) {
+				case "x":
+					w, _ = strconv.Atoi(strings.TrimSpace(kv[1]))
+				case "y":
+					h, _ = strconv.Atoi(strings.TrimSpace(kv[1]))
+				}
+			}
+			if w <= 0 || h <= 0 {
+				return nil, nil, fmt.Errorf("RLE header '%s' needs positive x and y", line)
+			}
+			f = NewBoard_BoolPacked(w, h)
+			f.boundary = boundary
+			continue
+		}
+
+		for _, c := range line {
+			switch {
+			case c >= '0' && c <= '9':
+				count = count*10 + int(c-'0')
+				continue
+			case c == ' ' || c == '\t':
+				continue
+			case c == '!':
+				return f, rule, nil
+			}
+
+			run := count
+			if run == 0 {
+				run = 1
+			}
+			count = 0
+
+			switch {
+			case c == '$':
+				x, y = 0, y+run
+			case c == 'b' || c == '.':
+				x += run
+			case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+				for ; run > 0; run-- { // Any other state counts as alive
+					f.Set_safe(x, y, true)
+					x++
+				}
+			default:
+				return nil, nil, fmt.Errorf("RLE line %d has unexpected '%c'", line_number+1, c)
+			}
+		}
+	}
+	if f == nil {
+		return nil, nil, fmt.Errorf("RLE has no header line")
+	}
+	return f, rule, nil // Missing '!' is forgiven
+}
+
+// LoadRLE overlays an RLE pattern onto the board, with its top-left corner at (0,0)
+// (anything that doesn't fit is dropped)
+func (f *Board_BoolPacked) LoadRLE(text string) (*Rule, error) {
+	pattern, rule, err := ParseRLE(text)
+	if err != nil {
+		return nil, err
+	}
+	for y := 0; y < pattern.h; y++ {
+		for x := 0; x < pattern.w; x++ {
+			if pattern.isSet(x, y) {
+				f.Set_safe(x, y, true)
+			}
+		}
+	}
+	return rule, nil
+}
+
+// toRLE returns the whole board (not just the bounding box, so that positions survive a round-trip)
+func (f *Board_BoolPacked) toRLE(rule *Rule) string {
+	var buf, line bytes.Buffer
+	rule_string := rule.String()
+	if f.boundary == Boundary_Torus {
+		rule_string += fmt.Sprintf(":T%d,%d", f.w, f.h)
+	}
+	buf.WriteString(fmt.Sprintf("x = %d, y = %d, rule = %s\n", f.w, f.h, rule_string))
+
+	// Lines are supposed to be kept to 70 characters
+	emit := func(run int, tag byte) {
+		item := string(tag)
+		if run > 1 {
+			item = strconv.Itoa(run) + item
+		}
+		if line.Len()+len(item) > 70 {
+			buf.Write(line.Bytes())
+			buf.WriteByte('\n')
+			line.Reset()
+		}
+		line.WriteString(item)
+	}
+
+	pending_rows := 0 // End-of-rows are only written once there's something after them
+	for y := 0; y < f.h; y++ {
+		run, state := 0, false
+		for x := 0; x < f.w; x++ {
+			alive := f.isSet(x, y)
+			if run > 0 && alive != state {
+				if pending_rows > 0 {
+					emit(pending_rows, '$')
+					pending_rows = 0
+				}
+				if state {
+					emit(run, 'o')
+				} else {
+					emit(run, 'b')
+				}
+				run = 0
+			}
+			state = alive
+			run++
+		}
+		if state { // Trailing dead cells are left off
+			if pending_rows > 0 {
+				emit(pending_rows, '$')
+				pending_rows = 0
+			}
+			emit(run, 'o')
+		}
+		pending_rows++
+	}
+	emit(1, '!')
+	buf.Write(line.Bytes())
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+// Plaintext (.cells) : '!' comment lines, then one line per row with '.' dead and 'O' alive
+// See http://www.conwaylife.com/wiki/Plaintext
+func ParseCells(text string) (*Board_BoolPacked, error) {
+	rows := []string{}
+	w := 0
+	for _, line := range strings.Split(strings.Replace(text, "\r", "", -1), "\n") {
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		rows = append(rows, line)
+		if len(line) > w {
+			w = len(line)
+		}
+	}
+	for len(rows) > 0 && strings.TrimSpace(rows[len(rows)-1]) == "" {
+		rows = rows[:len(rows)-1] // Trailing blank lines aren't part of the pattern
+	}
+	if w == 0 || len(rows) == 0 {
+		return nil, fmt.Errorf("Plaintext pattern is empty")
+	}
+
+	f := NewBoard_BoolPacked(w, len(rows))
+	for y, line := range rows {
+		for x, c := range line {
+			switch c {
+			case 'O', 'o', '*', 'X':
+				f.Set(x, y, true)
+			case '.', ' ', '-':
+			default:
+				return nil, fmt.Errorf("Plaintext row %d has unexpected '%c'", y+1, c)
+			}
+		}
+	}
+	return f, nil
+}
+
+// toCells writes every row in full (rather than trimming), so that positions survive a round-trip
+func (f *Board_BoolPacked) toCells(name string) string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("!Name: %s\n", name))
+	for y := 0; y < f.h; y++ {
+		for x := 0; x < f.w; x++ {
+			b := byte('.')
+			if f.isSet(x, y) {
+				b = 'O'
+			}
+			buf.WriteByte(b)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Life 1.06 : "#Life 1.06" header, then one "x y" line per live cell
+// See http://www.conwaylife.com/wiki/Life_1.06
+// There's no size information, so the board is the bounding box of the cells, 
+// unless all the coordinates are non-negative (as written by toLife106), in which case they're kept as-is
+func ParseLife106(text string) (*Board_BoolPacked, error) {
+	type cell struct{ x, y int }
+	cells := []cell{}
+	x_min, y_min, x_max, y_max := 0, 0, 0, 0
+	for line_number, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Life 1.06 line %d should be 'x y'", line_number+1)
+		}
+		x, err_x := strconv.Atoi(fields[0])
+		y, err_y := strconv.Atoi(fields[1])
+		if err_x != nil || err_y != nil {
+			return nil, fmt.Errorf("Life 1.06 line %d has bad coordinates", line_number+1)
+		}
+		if len(cells) == 0 || x < x_min {
+			x_min = x
+		}
+		if len(cells) == 0 || y < y_min {
+			y_min = y
+		}
+		if len(cells) == 0 || x > x_max {
+			x_max = x
+		}
+		if len(cells) == 0 || y > y_max {
+			y_max = y
+		}
+		cells = append(cells, cell{x, y})
+	}
+	if len(cells) == 0 {
+		return nil, fmt.Errorf("Life 1.06 pattern has no cells")
+	}
+	if x_min >= 0 && y_min >= 0 {
+		x_min, y_min = 0, 0 // Keep positions
+	}
+
+	f := NewBoard_BoolPacked(x_max-x_min+1, y_max-y_min+1)
+	for _, c := range cells {
+		f.Set(c.x-x_min, c.y-y_min, true)
+	}
+	return f, nil
+}
+
+func (f *Board_BoolPacked) toLife106() string {
+	var buf bytes.Buffer
+	buf.WriteString("#Life 1.06\n")
+	for y := 0; y < f.h; y++ {
+		for x := 0; x < f.w; x++ {
+			if f.isSet(x, y) {
+				buf.WriteString(fmt.Sprintf("%d %d\n", x, y))
+			}
+		}
+	}
+	return buf.String()
+}
+
+// LoadPatternFile picks the format from the file extension : .rle, .cells or .lif/.life (Life 1.06)
+// Only RLE carries a rule, the others get the current rule
+func LoadPatternFile(filename string) (*Board_BoolPacked, *Rule, error) {
+	text, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".rle":
+		return ParseRLE(string(text))
+	case ".cells":
+		f, err := ParseCells(string(text))
+		return f, board_rule, err
+	case ".lif", ".life":
+		if !strings.HasPrefix(string(text), "#Life 1.06") {
+			return nil, nil, fmt.Errorf("%s is not in Life 1.06 format", filename)
+		}
+		f, err := ParseLife106(string(text))
+		return f, board_rule, err
+	}
+	return nil, nil, fmt.Errorf("%s : Unknown pattern file type (expecting .rle, .cells or .lif)", filename)
+}
+
+func (f *Board_BoolPacked) SavePatternFile(filename string, rule *Rule) error {
+	var text string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".rle":
+		text = f.toRLE(rule)
+	case ".cells":
+		text = f.toCells(filepath.Base(filename))
+	case ".lif", ".life":
+		text = f.toLife106()
+	default:
+		return fmt.Errorf("%s : Unknown pattern file type (expecting .rle, .cells or .lif)", filename)
+	}
+	return ioutil.WriteFile(filename, []byte(text), 0644)
+}
@@ -0,0 +1,269 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"time"
+)
+
+// Exact one-step predecessors, by backtracking : The start board is filled in a row at a time, top row
+// first, and each row a cell at a time.  Once start row y is set, target row y-1 can't change any more,
+// and the target rows below only wait on the start rows below them.  So before filling in a row we work
+// out (backwards along it, from the rule's 3x3 window table) which column states can still be completed
+// to give the next few target rows, and never go down a path that can't.  Dead ends deeper than that
+// are remembered per row.
+// Only for Boundary_Dead boards (up to 62 wide), since the rows are uint64s with a padding bit each side
+
+// Start rows looked at together when filling one in (itself, and the two below it)
+const predecessor_lookahead = 3
+
+// With PredecessorSearch.random set, cells are tried live first 1 time in this many
+// (not often, since sparser predecessors are more likely to have predecessors themselves)
+const predecessor_live_first = 32
+
+const (
+	predecessor_states = 1 << (2 * predecessor_lookahead) // Column states
+	predecessor_cols   = 1 << predecessor_lookahead       // Values of the next column
+	no_step            = 0xff
+)
+
+type PredecessorSearch struct {
+	w, h int
+	goal []uint64 // Target rows (bit x+1 is cell x)
+	rows []uint64 // Start rows, including a dead row above and below (start row y is rows[y+1])
+	rule *Rule
+
+	// outputs[column of 5 start rows] : The 3 target cells in the middle of them, given the
+	// (3-cell) rows top<<12 | ... | bottom.  Target cell i (from the top) is bit i
+	outputs [1 << 15]uint8
+
+	// Recalculated each time start row y is started :
+	//   step[y][(x*states + s)*cols + col] : column_step(y, x, s, col), or no_step
+	//   feasible[y][x] : Bit s set if column state s after column x can still be completed
+	step     [][]uint8
+	feasible [][]uint64
+
+	limit   int
+	count   int
+	stopped bool
+	visit   func(start *Board_BoolPacked)
+	failed  map[row_state]bool // Row starts that led to no predecessors
+
+	deadline time.Time  // Give up (as if limit were reached) after this, unless it's zero
+	random   *rand.Rand // If set, cells are tried live first now and again, for a spread of predecessors (not the first few in order)
+	nodes    int
+}
+
+// All that matters to the rest of the search, when it's about to start on start row y
+type row_state struct {
+	y             int
+	above2, above uint64 // Start rows y-2 and y-1
+}
+
+func NewPredecessorSearch(target *Board_BoolPacked, rule *Rule) (*PredecessorSearch, error) {
+	if target.boundary != Boundary_Dead {
+		return nil, fmt.Errorf("Predecessor search needs a dead boundary (not %s)", target.boundary)
+	}
+	if target.w > 62 {
+		return nil, fmt.Errorf("Predecessor search only handles boards up to 62 wide (not %d)", target.w)
+	}
+	ps := &PredecessorSearch{
+		w: target.w, h: target.h,
+		goal:     make([]uint64, target.h),
+		rows:     make([]uint64, target.h+2),
+		rule:     rule,
+		step:     make([][]uint8, target.h),
+		feasible: make([][]uint64, target.h),
+		failed:   make(map[row_state]bool), // Kept between Enumerate()s, whatever order they go in
+	}
+	for y := 0; y < target.h; y++ {
+		for x := 0; x < target.w; x++ {
+			if target.isSet(x, y) {
+				ps.goal[y] |= 1 << uint(x+1)
+			}
+		}
+		ps.step[y] = make([]uint8, target.w*predecessor_states*predecessor_cols)
+		ps.feasible[y] = make([]uint64, target.w)
+	}
+	for column := range ps.outputs {
+		for i := uint(0); i < 3; i++ {
+			if rule.next[(column>>(6-3*i))&511] {
+				ps.outputs[column] |= 1 << i
+			}
+		}
+	}
+	return ps, nil
+}
+
+// While filling start row y, the column state after column x has 2 bits for each of start rows
+// y, y+1 and y+2 (bits 2i,2i+1 are columns x-1,x of start row y+i) : Row y is what's been filled in,
+// the others what they could be.  Adding column x+1 (bit i of col for start row y+i) settles the target
+// cells in column x of rows y-1, y and y+1.  Returns the new state, and whether those come out right
+// (want and care are the target cells, and which of them are on the board, from column_goal)
+func (ps *PredecessorSearch) column_step(y, x int, want, care uint8, s, col uint) (uint, bool) {
+	shift := uint(x) // Columns x-1..x+1 are bits x..x+2
+	column := uint((ps.rows[y]>>shift)&7) << 9
+	if y >= 1 {
+		column |= uint((ps.rows[y-1]>>shift)&7) << 12
+	}
+	for i := uint(0); i < 3; i++ {
+		column |= ((s>>(2*i))&3 | ((col>>i)&1)<<2) << (6 - 3*i)
+	}
+	if (ps.outputs[column]^want)&care != 0 {
+		return 0, false
+	}
+	return (s>>1)&0x15 | (col&1)<<1 | (col&2)<<2 | (col&4)<<3, true
+}
+
+func (ps *PredecessorSearch) column_goal(y, x int) (want, care uint8) {
+	for i := 0; i < 3; i++ {
+		if t := y - 1 + i; t >= 0 && t < ps.h {
+			care |= 1 << uint(i)
+			if ps.goal[t]&(2<<uint(x)) != 0 {
+				want |= 1 << uint(i)
+			}
+		}
+	}
+	return want, care
+}
+
+// Which start rows (bit i for y+i) are on the board : The ones below it are dead
+func (ps *PredecessorSearch) col_mask(y int) uint {
+	mask := uint(0)
+	for i := 0; i < predecessor_lookahead && y+i < ps.h; i++ {
+		mask |= 1 << uint(i)
+	}
+	return mask
+}
+
+func (ps *PredecessorSearch) search_row(y int) {
+	if y == ps.h {
+		ps.count++
+		if ps.visit != nil {
+			start := NewBoard_BoolPacked(ps.w, ps.h)
+			start.boundary = Boundary_Dead
+			for sy := 0; sy < ps.h; sy++ {
+				start.s[(sy+1)*start.stride] = ps.rows[sy+1] // Both have cell x at bit x+1
+			}
+			ps.visit(start)
+		}
+		if ps.limit > 0 && ps.count >= ps.limit {
+			ps.stopped = true
+		}
+		return
+	}
+	if y >= 1 {
+		state := row_state{y, ps.rows[y-1], ps.rows[y]}
+		if ps.failed[state] {
+			return
+		}
+		before := ps.count
+		defer func() {
+			if ps.count == before && !ps.stopped {
+				ps.failed[state] = true
+			}
+		}()
+	}
+
+	// Work backwards along the row : The last column is followed by dead cells
+	step, feasible := ps.step[y], ps.feasible[y]
+	mask := ps.col_mask(y)
+	for x := ps.w - 1; x >= 0; x-- {
+		feasible[x] = 0
+		want, care := ps.column_goal(y, x)
+		for s := uint(0); s < predecessor_states; s++ {
+			for col := uint(0); col < predecessor_cols; col++ {
+				i := (x*predecessor_states+int(s))*predecessor_cols + int(col)
+				step[i] = no_step
+				if col&^mask != 0 || (x == ps.w-1 && col != 0) {
+					continue
+				}
+				if n, ok := ps.column_step(y, x, want, care, s, col); ok {
+					step[i] = uint8(n)
+					if x == ps.w-1 || feasible[x+1]&(1<<n) != 0 {
+						feasible[x] |= 1 << s
+					}
+				}
+			}
+		}
+	}
+	ps.search_cell(y, 0, 0)
+}
+
+// Fill in start cell (x,y), given the possible column states after column x-1
+func (ps *PredecessorSearch) search_cell(y, x int, states uint64) {
+	if x == ps.w {
+		ps.search_row(y + 1)
+		return
+	}
+	ps.nodes++
+	if ps.nodes&0xfff == 0 && !ps.deadline.IsZero() && time.Now().After(ps.deadline) {
+		ps.stopped = true
+	}
+
+	mask := ps.col_mask(y)
+	bit := uint64(1) << uint(x+1)
+	first := uint(0)
+	if ps.random != nil && ps.random.Intn(predecessor_live_first) == 0 {
+		first = 1
+	}
+	for i := uint(0); i <= 1 && !ps.stopped; i++ {
+		b := first ^ i
+		next := uint64(0)
+		for col := b; col <= mask; col += 2 { // Start row y is b, the ones below can be anything
+			if col&^mask != 0 {
+				continue
+			}
+			if x == 0 { // Nothing to the left
+				n := uint(0)
+				for i := uint(0); i < predecessor_lookahead; i++ {
+					n |= ((col >> i) & 1) << (2*i + 1)
+				}
+				next |= 1 << n
+				continue
+			}
+			for rest := states; rest != 0; rest &= rest - 1 {
+				s := bits.TrailingZeros64(rest)
+				if n := ps.step[y][((x-1)*predecessor_states+s)*predecessor_cols+int(col)]; n != no_step {
+					next |= 1 << n
+				}
+			}
+		}
+		next &= ps.feasible[y][x]
+		if next == 0 {
+			continue
+		}
+		if b == 1 {
+			ps.rows[y+1] |= bit
+		}
+		ps.search_cell(y, x+1, next)
+		ps.rows[y+1] &^= bit
+	}
+}
+
+// Enumerate calls visit (if it isn't nil) for each predecessor, stopping after limit of them (if limit>0)
+// or at the deadline.  Returns the number found, and whether that's all of them
+func (ps *PredecessorSearch) Enumerate(limit int, visit func(start *Board_BoolPacked)) (int, bool) {
+	ps.limit, ps.count, ps.stopped, ps.visit, ps.nodes = limit, 0, false, visit, 0
+	for i := range ps.rows {
+		ps.rows[i] = 0
+	}
+	ps.search_row(0)
+	return ps.count, !ps.stopped
+}
+
+// Nearest enumerates (up to limit) predecessors, and gives the smallest number of cells by which
+// candidate differs from one of them (-1 if there are none), along with the Enumerate results
+func (ps *PredecessorSearch) Nearest(candidate *Board_BoolPacked, limit int) (int, int, bool) {
+	nearest := -1
+	n, complete := ps.Enumerate(limit, func(start *Board_BoolPacked) {
+		if d := start.CompareTo(candidate, nil); nearest < 0 || d < nearest {
+			nearest = d
+		}
+	})
+	return nearest, n, complete
+}
@@ -0,0 +1,183 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+// Per-cell probabilities from the transition statistics : Rather than sampling a whole 5x5 start patch
+// for an end patch (as the GA's mutation does), add up the counts into P(start cell alive | 5x5 end patch
+// around it, steps).  The centre of a patch doesn't move under the D4 symmetries, so the canonical end
+// patches can be used as they are.
+
+type CellProbabilities struct {
+	alive   map[Patch]float32 // By canonical end patch
+	overall float32           // For end patches that were never seen
+}
+
+func NewCellProbabilities(tc *TransitionCollectionList) *CellProbabilities {
+	cp := &CellProbabilities{alive: make(map[Patch]float32, len(tc.pre))}
+	alive_total, freq_total := 0, 0
+	for end, pl := range tc.pre {
+		alive := 0
+		for _, pf := range pl.starts {
+			if pf.patch.isSet(2, 2) {
+				alive += pf.freq
+			}
+		}
+		if pl.freq_total > 0 {
+			cp.alive[end] = float32(alive) / float32(pl.freq_total)
+		}
+		alive_total += alive
+		freq_total += pl.freq_total
+	}
+	if freq_total > 0 {
+		cp.overall = float32(alive_total) / float32(freq_total)
+	}
+	return cp
+}
+
+// Alive is P(start cell alive) for the (not necessarily canonical) end patch around it
+func (cp *CellProbabilities) Alive(end Patch) float32 {
+	if p, ok := cp.alive[end.BestOrientation().patch]; ok {
+		return p
+	}
+	return cp.overall
+}
+
+type ProbabilityMap struct {
+	p    [][]float32 // [y][x] : P(start cell alive)
+	w, h int
+}
+
+// Map gives the probability of each start cell being alive, for the end board
+func (cp *CellProbabilities) Map(end *Board_BoolPacked) *ProbabilityMap {
+	pm := &ProbabilityMap{p: make([][]float32, end.h), w: end.w, h: end.h}
+	for y := 0; y < end.h; y++ {
+		pm.p[y] = make([]float32, end.w)
+		for x := 0; x < end.w; x++ {
+			pm.p[y][x] = cp.Alive(end.MakePatch(x, y))
+		}
+	}
+	return pm
+}
+
+// Threshold gives the start board with the cells that are more likely than level to be alive
+// (0.5 gives the fewest expected errors)
+func (pm *ProbabilityMap) Threshold(level float32) *Board_BoolPacked {
+	f := NewBoard_BoolPacked(pm.w, pm.h)
+	for y := 0; y < pm.h; y++ {
+		for x := 0; x < pm.w; x++ {
+			f.Set(x, y, pm.p[y][x] > level)
+		}
+	}
+	return f
+}
+
+// ExpectedErrors is the number of cells Threshold(0.5) should get wrong, if the probabilities are right
+func (pm *ProbabilityMap) ExpectedErrors() float32 {
+	e := float32(0)
+	for y := 0; y < pm.h; y++ {
+		for x := 0; x < pm.w; x++ {
+			if p := pm.p[y][x]; p < 0.5 {
+				e += p
+			} else {
+				e += 1 - p
+			}
+		}
+	}
+	return e
+}
+
+// AddToStats counts the map as weight votes (shared out by probability), for voting alongside solutions
+func (pm *ProbabilityMap) AddToStats(bs *BoardStats, weight int) {
+	for y := 0; y < pm.h; y++ {
+		for x := 0; x < pm.w; x++ {
+			bs.freq[y][x] += int(pm.p[y][x]*float32(weight) + 0.5)
+		}
+	}
+	bs.count += weight
+}
+
+// SampleFrom sets the cells within radius of (x,y) at random, according to their probabilities
+func (f *Board_BoolPacked) SampleFrom(pm *ProbabilityMap, x, y, radius int, random *rand.Rand) {
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if x+dx >= 0 && x+dx < f.w && y+dy >= 0 && y+dy < f.h {
+				f.Set(x+dx, y+dy, random.Float32() < pm.p[y+dy][x+dx])
+			}
+		}
+	}
+}
+
+// Baseline submission : Threshold(0.5) of each problem's probability map, with no GA at all.
+// On the fake training set (ids 60001..61000), the file is scored too
+func create_probability_submission(fname string, is_training bool) {
+	var kaggle LifeProblemSet
+
+	id_list := []int{}
+	if is_training {
+		for id := 60001; id <= 61000; id++ {
+			id_list = append(id_list, id)
+		}
+	} else {
+		for id := 1; id <= 50000; id++ {
+			id_list = append(id_list, id)
+		}
+	}
+	kaggle.load_csv(is_training, id_list)
+
+	file, err := os.Create(fname)
+	if err != nil {
+		fmt.Println("File Creation Error:", err)
+		return
+	}
+	defer file.Close()
+
+	file.WriteString("id")
+	for i := 1; i <= board_width*board_height; i++ {
+		file.WriteString(fmt.Sprintf(",start.%d", i))
+	}
+	file.WriteString("\n")
+
+	t0 := time.Now()
+	expected_errors := make(map[int]float32) // By steps
+	count := make(map[int]int)
+	for _, id := range id_list {
+		problem, ok := kaggle.problem[id]
+		if !ok {
+			fmt.Printf("BAD SUBMISSION FILE :: problem[%d] is missing\n", id)
+			return
+		}
+		pm := kaggle.cell_probabilities_for(problem.steps).Map(problem.end)
+		expected_errors[problem.steps] += pm.ExpectedErrors()
+		count[problem.steps]++
+
+		file.WriteString(fmt.Sprintf("%d", id))
+		file.WriteString(pm.Threshold(0.5).toCSV())
+		file.WriteString("\n")
+	}
+	fmt.Printf("Probability submission created : %s (%.1fs)\n", fname, time.Since(t0).Seconds())
+
+	steps_list := []int{}
+	for steps := range count {
+		steps_list = append(steps_list, steps)
+	}
+	sort.Ints(steps_list)
+	for _, steps := range steps_list {
+		fmt.Printf("  steps=%d : %5d problems, expected error rate %6.4f\n",
+			steps, count[steps], expected_errors[steps]/float32(count[steps]*board_width*board_height))
+	}
+}
+
+// The model for steps, made (along with the transition collection it comes from) the first time it's asked for
+func (s *LifeProblemSet) cell_probabilities_for(steps int) *CellProbabilities {
+	s.load_transition_collection(steps)
+	return s.cell_probabilities[steps]
+}
@@ -0,0 +1,145 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// GA parameters, from a JSON run profile (-profile) and/or the command line (-ga) :
+//   {
+//     "ga":    { "pop_size":1000, "iter_max":2000, "crossover_pct":30 },
+//     "delta": { "4": { "iter_max":4000 }, "5": { "iter_max":4000, "mutation_radius":3 } }
+//   }
+// Anything left out keeps its default.  The per-delta settings go on top of the "ga" ones, and -ga on top
+// of both, e.g. -ga=pop_size=500,mutation_pct=60.  The effective set is saved with each solution.
+
+type GAParams struct {
+	PopSize         int `json:"pop_size"`
+	IterMax         int `json:"iter_max"`
+	Checkpoints     int `json:"checkpoints"`       // Generations between progress lines (and the has-it-stopped-improving test)
+	PressurePct     int `json:"pressure_pct"`      // (50..100) = Prob(Chose better of two random individuals)
+	MutationPct     int `json:"mutation_pct"`      // (0..100)
+	MutationLoopPct int `json:"mutation_loop_pct"` // (0..100)
+	MutationRadius  int `json:"mutation_radius"`   // 0 = steps
+	CrossoverPct    int `json:"crossover_pct"`     // (0..100)
+	Adaptive        int `json:"adaptive"`          // 1 = adapt the operator mix and mutation radius as it goes (see adapt.go)
+	Dedup           int `json:"dedup"`             // 1 = no copies of the same board in a generation (see diversity.go)
+	Crowding        int `json:"crowding"`          // 1 = deterministic crowding rather than tournaments
+	ImmigrantPct    int `json:"immigrant_pct"`     // (0..100) New random boards in each generation
+}
+
+var default_ga_params = GAParams{
+	PopSize:         1000,
+	IterMax:         2000,
+	Checkpoints:     20,
+	PressurePct:     90,
+	MutationPct:     50,
+	MutationLoopPct: 70,
+	MutationRadius:  0,
+	CrossoverPct:    30,
+	Adaptive:        0,
+	Dedup:           0,
+	Crowding:        0,
+	ImmigrantPct:    0,
+}
+
+type GAProfile struct {
+	GA    json.RawMessage            `json:"ga"`
+	Delta map[string]json.RawMessage `json:"delta"`
+
+	overrides json.RawMessage // From the command line
+}
+
+var ga_profile GAProfile
+
+// Put layer on top of p : Only the fields that are in layer change
+func (p *GAParams) apply(layer json.RawMessage) error {
+	if len(layer) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(layer))
+	dec.DisallowUnknownFields() // Catch typos, rather than quietly running with the defaults
+	return dec.Decode(p)
+}
+
+func LoadGAProfile(fname string) error {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	var profile GAProfile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&profile); err != nil {
+		return fmt.Errorf("%s : %v", fname, err)
+	}
+	// Check every layer now, so that ga_params can't fail later
+	var p GAParams
+	if err := p.apply(profile.GA); err != nil {
+		return fmt.Errorf("%s : ga : %v", fname, err)
+	}
+	for delta, layer := range profile.Delta {
+		if _, err := strconv.Atoi(delta); err != nil {
+			return fmt.Errorf("%s : delta '%s' isn't a number", fname, delta)
+		}
+		if err := p.apply(layer); err != nil {
+			return fmt.Errorf("%s : delta %s : %v", fname, delta, err)
+		}
+	}
+	profile.overrides = ga_profile.overrides
+	ga_profile = profile
+	return nil
+}
+
+// SetGAOverrides takes "name=value,name=value" (with the JSON names, e.g. pop_size=500)
+func SetGAOverrides(s string) error {
+	fields := []string{}
+	for _, setting := range strings.Split(s, ",") {
+		if setting == "" {
+			continue
+		}
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("GA setting '%s' should be name=value", setting)
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return fmt.Errorf("GA setting '%s' should have a whole number value", setting)
+		}
+		fields = append(fields, fmt.Sprintf("%q:%d", strings.TrimSpace(kv[0]), value))
+	}
+	overrides := json.RawMessage("{" + strings.Join(fields, ",") + "}")
+	var p GAParams
+	if err := p.apply(overrides); err != nil {
+		return fmt.Errorf("GA settings '%s' : %v", s, err)
+	}
+	ga_profile.overrides = overrides
+	return nil
+}
+
+// ga_params is the effective parameter set for problems with this many steps
+func ga_params(steps int) GAParams {
+	p := default_ga_params
+	p.apply(ga_profile.GA) // Errors were caught when these were loaded
+	p.apply(ga_profile.Delta[strconv.Itoa(steps)])
+	p.apply(ga_profile.overrides)
+	if p.MutationRadius <= 0 {
+		p.MutationRadius = steps
+	}
+	if p.Checkpoints <= 0 {
+		p.Checkpoints = 1
+	}
+	return p
+}
+
+func (p GAParams) String() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
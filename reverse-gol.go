@@ -1,12 +1,15 @@
 package main
 
-// GOPATH=`pwd` go build reverse-gol.go speed_packed.go ga.go board-standard.go transitions.go db.go && ./reverse-gol
+// GOPATH=`pwd` go build reverse-gol.go speed_packed.go speed_adder.go speed_sliced.go rule.go patterns.go ga.go board-standard.go board-algebra.go objects.go orphans.go predecessors.go chain.go probability.go solver.go incremental.go anneal.go walksat.go islands.go profile.go adapt.go diversity.go sat.go sat-life.go transitions.go db.go && ./reverse-gol
 
 import (
 	"fmt"
 	"time"
 	"math/rand"
 	"flag"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 
@@ -54,6 +57,103 @@ X-X
 	fmt.Printf("1000 iterations took %s\n", elapsed)
 }
 
+// Cross-check each engine against Iterate_Generic on random boards, and time them
+func main_check_engines() {
+	sizes := [][2]int{{board_width, board_height}, {8, 8}, {63, 17}, {200, 50}}
+	rules := []*Rule{board_rule, Rule_Conway}
+	for _, name := range []string{"B36/S23", "B3678/S34678", "B2/S"} {
+		r, _ := ParseRule(name)
+		rules = append(rules, r)
+	}
+	
+	saved_engine := board_engine
+	defer func() { board_engine = saved_engine }()
+	
+	for _, size := range sizes {
+		w, h := size[0], size[1]
+		for _, rule := range rules {
+			failures := 0
+			for iter := 0; iter < 100; iter++ {
+				f := NewBoard_BoolPacked(w, h)
+				f.UniformRandom(rand.Float32())
+				
+				generic := NewBoard_BoolPacked(w, h)
+				f.Iterate_Generic(generic, rule)
+				
+				for _, engine := range []Engine{Engine_Packed, Engine_Adder} {
+					board_engine = engine
+					next := NewBoard_BoolPacked(w, h)
+					f.IterateRule(next, rule)
+					if mismatch := next.CompareTo(generic, nil); mismatch > 0 {
+						fmt.Printf("Engine %d FAIL on %dx%d %s - by %d\n", engine, w, h, rule, mismatch)
+						failures++
+					}
+				}
+			}
+			fmt.Printf("%4dx%-4d %-14s : %d failures\n", w, h, rule, failures)
+		}
+	
+		for _, engine := range []Engine{Engine_Packed, Engine_Adder} {
+			board_engine = engine
+			l := NewBoardIterator(w, h)
+			l.current.UniformRandom(0.3)
+			
+			start := time.Now()
+			l.Iterate(10000)
+			fmt.Printf("%4dx%-4d engine %d : 10000 iterations took %s\n", w, h, engine, time.Since(start))
+		}
+	}
+	
+	// The GA scores its individuals with BoardBatch, so that has to agree with IterateRule too
+	fmt.Printf("BoardBatch vs IterateRule : %d failures\n", check_batch_engine(rules, 300))
+}
+
+// Random batches (any size, boundary, rule and number of steps) : Do BoardBatch's mismatch counts and diffs
+// match running each board through IterateRule and CompareTo_Masked?  Returns the number of failures
+func check_batch_engine(rules []*Rule, cases int) int {
+	failures := 0
+	for c := 0; c < cases; c++ {
+		w, h := 1+rand.Intn(80), 1+rand.Intn(40)
+		boundary := []Boundary{Boundary_Dead, Boundary_Torus, Boundary_Unknown}[rand.Intn(3)]
+		rule := rules[rand.Intn(len(rules))]
+		steps := 1+rand.Intn(5)
+		
+		target := NewBoard_BoolPacked(w, h)
+		target.boundary = boundary
+		target.UniformRandom(rand.Float32())
+		known_mask := target.KnownMask(steps)
+		
+		starts := make([]*Board_BoolPacked, 1+rand.Intn(BoardBatchSize))
+		diffs := make([]*Board_BoolPacked, len(starts))
+		for i := range starts {
+			starts[i] = NewBoard_BoolPacked(w, h)
+			starts[i].boundary = boundary
+			starts[i].UniformRandom(rand.Float32())
+			diffs[i] = NewBoard_BoolPacked(w, h)
+		}
+		batch := NewBoardBatch(w, h, rule)
+		batch.Load(starts)
+		batch.Iterate(steps)
+		mismatches := batch.CompareTo_Masked(target, diffs, known_mask)
+		
+		for i, start := range starts {
+			current, next := NewBoard_BoolPacked(w, h), NewBoard_BoolPacked(w, h)
+			current.CopyFrom(start)
+			for step := 0; step < steps; step++ {
+				current.IterateRule(next, rule)
+				current, next = next, current
+			}
+			diff := NewBoard_BoolPacked(w, h)
+			mismatch := current.CompareTo_Masked(target, diff, known_mask)
+			if mismatch != mismatches[i] || diff.CompareTo(diffs[i], nil) > 0 {
+				fmt.Printf("BoardBatch FAIL on %dx%d %s boundary=%d steps=%d board %d - mismatch %d vs %d\n", w, h, rule, boundary, steps, i, mismatches[i], mismatch)
+				failures++
+			}
+		}
+	}
+	return failures
+}
+
 func main_loader() {
 	for pct := float32(0.1); pct < 1.0; pct += 0.1 {
 		l := NewBoardIterator(board_width, board_height)
@@ -115,6 +215,356 @@ func main_verify_training_examples(problem_offset int) {
 	image.save("images/train.png")
 }
 
+// What happens to the end boards, if they are run forwards?  
+// e.g. if an end board is a still-life, then it is its own predecessor, for any number of steps
+func main_analyze_fates(is_training bool, id_first int, count int) {
+	var kaggle LifeProblemSet
+	
+	id_list := []int{}
+	for id := id_first; id < id_first+count; id++ {
+		id_list = append(id_list, id)
+	}
+	kaggle.load_csv(is_training, id_list)
+	
+	tally := make(map[Fate]int)
+	for _, id := range id_list {
+		problem, ok := kaggle.problem[id]
+		if !ok {
+			continue
+		}
+		l := NewBoardIterator(problem.end.w, problem.end.h)
+		l.rule = problem.Rule()
+		l.current.CopyFrom(problem.end)
+		
+		report := l.Analyze(1000)
+		tally[report.fate]++
+		
+		note := ""
+		if report.fate == Fate_StillLife && report.cycle_start == 0 {
+			note = " : end board is its own predecessor"
+		}
+		fmt.Printf("problem[%d].steps=%d end board %s%s\n", id, problem.steps, report, note)
+	}
+	
+	for fate := Fate_Undetermined; fate <= Fate_Oscillator; fate++ {
+		fmt.Printf("%-12s : %5d\n", fate, tally[fate])
+	}
+}
+
+func main_analyze_orphans(is_training bool, id_first int, count int) {
+	var kaggle LifeProblemSet
+	
+	id_list := []int{}
+	for id := id_first; id < id_first+count; id++ {
+		id_list = append(id_list, id)
+	}
+	kaggle.load_csv(is_training, id_list)
+	
+	count_with_orphans, total_min_errors := 0, 0
+	for _, id := range id_list {
+		problem, ok := kaggle.problem[id]
+		if !ok {
+			continue
+		}
+		report := problem.end.FindOrphans(problem.Rule(), problem.end.KnownMask(problem.steps))
+		if report.orphans == 0 {
+			continue
+		}
+		count_with_orphans++
+		total_min_errors += report.min_errors
+		fmt.Printf("problem[%d].steps=%d end board has %s : unfixable cells\n", id, problem.steps, report)
+		fmt.Print(report.unfixable)
+	}
+	fmt.Printf("%d problems have orphans, with at least %d unmatchable cells in total\n", count_with_orphans, total_min_errors)
+}
+
+func main_analyze_predecessors(is_training bool, id_first int, count int, limit int) {
+	var kaggle LifeProblemSet
+	
+	id_list := []int{}
+	for id := id_first; id < id_first+count; id++ {
+		id_list = append(id_list, id)
+	}
+	kaggle.load_csv(is_training, id_list)
+	
+	searched, total, complete_count, true_start_found := 0, 0, 0, 0
+	for _, id := range id_list {
+		problem, ok := kaggle.problem[id]
+		if !ok || problem.steps != 1 { // Only one step back is exact
+			continue
+		}
+		ps, err := NewPredecessorSearch(problem.end, problem.Rule())
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		
+		// On the test set there's no true start, so just measure against the end board itself
+		candidate, candidate_name := problem.end, "end board"
+		if is_training {
+			candidate, candidate_name = problem.start, "true start"
+		}
+		
+		t0 := time.Now()
+		nearest, n, complete := ps.Nearest(candidate, limit)
+		searched++
+		total += n
+		at_least := "at least "
+		if complete {
+			complete_count++
+			at_least = ""
+		}
+		if is_training && nearest == 0 {
+			true_start_found++
+		}
+		fmt.Printf("problem[%d] has %s%d predecessors (%.1fs) : %s is %d cells from the nearest\n",
+			id, at_least, n, time.Since(t0).Seconds(), candidate_name, nearest)
+	}
+	fmt.Printf("%d one-step problems searched, %d predecessors found, %d searches complete\n", searched, total, complete_count)
+	if is_training {
+		fmt.Printf("True start was among the predecessors found for %d of them\n", true_start_found)
+	}
+}
+
+// Chained one-step reversal vs the direct k-step GA, on the same problems
+func main_analyze_chain(is_training bool, id_first int, count int) {
+	var kaggle LifeProblemSet
+	
+	id_list := []int{}
+	for id := id_first; id < id_first+count; id++ {
+		id_list = append(id_list, id)
+	}
+	kaggle.load_csv(is_training, id_list)
+	
+	type Totals struct {
+		problems, exact, start_mismatch int
+		seconds float64
+	}
+	var chain, ga Totals
+	add := func(t *Totals, r *IndividualResult, t0 time.Time) {
+		t.problems++
+		if r.mismatch_from_true_end_final == 0 {
+			t.exact++
+		}
+		t.start_mismatch += r.mismatch_from_true_start_final
+		t.seconds += time.Since(t0).Seconds()
+	}
+	
+	for _, id := range id_list {
+		problem, ok := kaggle.problem[id]
+		if !ok {
+			continue
+		}
+		kaggle.load_transition_collection(problem.steps)
+		
+		t0 := time.Now()
+		chain_result := Solver_Chain{}.Solve(problem, &kaggle, 0, rand.New(rand.NewSource(rand.Int63())))
+		add(&chain, chain_result, t0)
+		
+		t0 = time.Now()
+		ga_result := create_solution(problem, &kaggle)
+		add(&ga, ga_result, t0)
+		
+		fmt.Printf("problem[%d].steps=%d : chain end mismatch %d, start mismatch %d ; GA end mismatch %d, start mismatch %d\n", 
+			id, problem.steps, 
+			chain_result.mismatch_from_true_end_final, chain_result.mismatch_from_true_start_final, 
+			ga_result.mismatch_from_true_end_final, ga_result.mismatch_from_true_start_final)
+	}
+	
+	report := func(name string, t Totals) {
+		if t.problems > 0 {
+			n := float64(t.problems)
+			fmt.Printf("%-5s : %d/%d exact, mean start mismatch %.1f, mean time %.1fs\n", 
+				name, t.exact, t.problems, float64(t.start_mismatch)/n, t.seconds/n)
+		}
+	}
+	report("chain", chain)
+	report("GA", ga)
+	if !is_training {
+		fmt.Println("(Start mismatches are only meaningful on the training set)")
+	}
+}
+
+func main_analyze_census(is_training bool, id_first int, count int, distance int) {
+	var kaggle LifeProblemSet
+	
+	id_list := []int{}
+	for id := id_first; id < id_first+count; id++ {
+		id_list = append(id_list, id)
+	}
+	kaggle.load_csv(is_training, id_list)
+	
+	census := make(map[int]*Census)
+	tally := make(map[string]int)
+	for _, id := range id_list {
+		problem, ok := kaggle.problem[id]
+		if !ok {
+			continue
+		}
+		census[id] = problem.end.Census(distance, problem.Rule())
+		for name, n := range census[id].Tally() {
+			tally[name] += n
+		}
+		fmt.Printf("problem[%d].steps=%d end board : %s\n", id, problem.steps, census[id])
+	}
+	
+	names := []string{}
+	for name := range tally {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%-20s : %5d\n", name, tally[name])
+	}
+	
+	set := "test"
+	if is_training {
+		set = "train"
+	}
+	SaveCensusCSV(fmt.Sprintf("stats/census-%s.csv", set), census)
+}
+
+func main_visualize_pattern(filename string) {
+	board, rule, err := LoadPatternFile(filename)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("%s : %dx%d, rule %s\n", filename, board.w, board.h, rule)
+	fmt.Print(board)
+	
+	l := NewBoardIterator(board.w, board.h)
+	l.rule = rule
+	l.current.CopyFrom(board)
+	fmt.Printf("Fate : %s\n", l.Analyze(1000))
+}
+
+// Write the problems' boards as pattern files (start boards are only known for training)
+// extension is one of {rle|cells|lif}
+func main_export_patterns(is_training bool, id_first int, count int, extension string) {
+	var kaggle LifeProblemSet
+	
+	id_list := []int{}
+	for id := id_first; id < id_first+count; id++ {
+		id_list = append(id_list, id)
+	}
+	kaggle.load_csv(is_training, id_list)
+	
+	prefix := "test"
+	if is_training {
+		prefix = "train"
+	}
+	for _, id := range id_list {
+		problem, ok := kaggle.problem[id]
+		if !ok {
+			continue
+		}
+		boards := map[string]*Board_BoolPacked{"end": problem.end}
+		if is_training {
+			boards["start"] = problem.start
+		}
+		for name, board := range boards {
+			fname := fmt.Sprintf("patterns/%s-%d-%s.%s", prefix, id, name, extension)
+			if err := board.SavePatternFile(fname, problem.Rule()); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			fmt.Printf("Wrote %s\n", fname)
+		}
+	}
+}
+
+// The k-step predecessor constraints as DIMACS files for stand-alone SAT solvers, e.g. cnf/train-50-delta1.cnf
+func cnf_filename(is_training bool, id int, steps int) string {
+	prefix := "test"
+	if is_training {
+		prefix = "train"
+	}
+	return fmt.Sprintf("cnf/%s-%d-delta%d.cnf", prefix, id, steps)
+}
+
+func main_export_cnf(is_training bool, id_first int, count int) {
+	var kaggle LifeProblemSet
+	
+	id_list := []int{}
+	for id := id_first; id < id_first+count; id++ {
+		id_list = append(id_list, id)
+	}
+	kaggle.load_csv(is_training, id_list)
+	
+	for _, id := range id_list {
+		problem, ok := kaggle.problem[id]
+		if !ok {
+			continue
+		}
+		cnf := NewLifeCNF(problem.end, problem.steps, problem.Rule())
+		fname := cnf_filename(is_training, id, problem.steps)
+		title := fmt.Sprintf("reverse-gol problem[%d].steps=%d (training=%t)", id, problem.steps, is_training)
+		if err := cnf.SaveDIMACS(fname, title, problem.Rule()); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Wrote %s : %d variables, %d clauses\n", fname, cnf.n_vars, len(cnf.clauses))
+	}
+}
+
+// Read a stand-alone solver's model for the problem's .cnf (as written by main_export_cnf), 
+// and store the start board in the db like any GA solution
+func main_import_model(is_training bool, id int, model_filename string) {
+	var kaggle LifeProblemSet
+	kaggle.load_csv(is_training, []int{id})
+	problem, ok := kaggle.problem[id]
+	if !ok {
+		fmt.Printf("problem[%d] not found\n", id)
+		return
+	}
+	
+	start, err := LoadDIMACSStart(cnf_filename(is_training, id, problem.steps), model_filename)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	individual_result := solution_result(problem, start, is_training)
+	fmt.Printf("problem[%d].steps=%d : Model has mismatch vs end = %d\n", id, problem.steps, individual_result.mismatch_from_true_end_final)
+	fmt.Print(start)
+	
+	seed := get_unprocessed_seed_from_db(id, is_training)
+	save_solution_to_db(id, problem.steps, seed, individual_result, is_training)
+}
+
+// Find a start board for the pattern in the file (which is taken as the end board), 
+// and write it alongside, e.g. patterns/target.rle -> patterns/target-start-delta1.rle
+func main_solve_pattern(filename string, steps int) {
+	end, rule, err := LoadPatternFile(filename)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	
+	// The rest of the machinery works on the current board size and rule
+	board_width, board_height = end.w, end.h
+	board_empty = NewBoard_BoolPacked(board_width, board_height)
+	board_rule = rule
+	
+	var lps LifeProblemSet
+	lps.problem = map[int]LifeProblem{ 0:LifeProblem{id:0, end:end, steps:steps, rule:rule} }
+	lps.load_transition_collection(steps)
+	
+	individual_result := solve_problem(lps.problem[0], &lps, rand.New(rand.NewSource(rand.Int63())))
+	start := individual_result.individual.start
+	
+	fmt.Printf("%s : delta=%d, rule %s, mismatch vs end = %d\n", filename, steps, rule, individual_result.mismatch_from_true_end_final)
+	fmt.Print(start)
+	
+	ext := filepath.Ext(filename)
+	fname := fmt.Sprintf("%s-start-delta%d%s", strings.TrimSuffix(filename, ext), steps, ext)
+	if err := start.SavePatternFile(fname, rule); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Wrote %s\n", fname)
+}
+
 func main_visualize_density() {
 	image := NewImageSet(10, 11) // 10 rows of 11 images each, formatted 'appropriately'
 
@@ -162,8 +612,9 @@ func main_population_score(is_training bool, id int) {
 	problem.end.AddToStats(bs_end)
 
 	// Create a population of potential boards
-	pop_size := 1000
-	pop := NewPopulation(pop_size, problem.steps, problem.end, &kaggle.transition_collection[problem.steps])
+	params := ga_params(problem.steps)
+	pop_size := params.PopSize
+	pop := NewPopulation(pop_size, params, problem.end, &kaggle.transition_collection[problem.steps])
 	for i:=0; i<pop_size; i++ {
 		// Create a candidate starting point
 		// NB:  We can only work from the problem.end
@@ -171,10 +622,13 @@ func main_population_score(is_training bool, id int) {
 		//pop.individual[i].start.UniformRandom(0.32)
 	}
 	
-	p_temp := NewPopulation(pop_size, problem.steps, problem.end, &kaggle.transition_collection[problem.steps])
+	p_temp := NewPopulation(pop_size, params, problem.end, &kaggle.transition_collection[problem.steps])
 
 	l := NewBoardIterator(board_width, board_height)
 	
+	// For Boundary_Unknown, don't penalize cells that the outside could have reached
+	known_mask := problem.end.KnownMask(problem.steps)
+	
 	iter_max := 1000
 	for iter:=0; iter<iter_max; iter++ {
 		disp_row := (0 == (iter) % (iter_max/10))
@@ -209,10 +663,10 @@ func main_population_score(is_training bool, id int) {
 			l.Iterate(problem.steps)
 			
 			// This is 'allowed' since we know the end result, and can store the diff
-			mismatch_from_true_end := l.current.CompareTo(problem.end, individual.diff)
+			mismatch_from_true_end := l.current.CompareTo_Masked(problem.end, individual.diff, known_mask)
 			
 			// This is a lower factor pressure, but good to have too
-			count_on := individual.start.CompareTo(board_empty, nil)
+			count_on := individual.start.Popcount()
 			
 			//individual.fitness = -mismatch_from_true_end
 			//individual.fitness = -mismatch_from_true_end*4 -count_on*1
@@ -277,11 +731,13 @@ func main_create_stats(steps int, use_training_data bool) {
 		// 3  9947 589k 427k 411k
 		// 4 10089 565k 410k 394k
 		// 5  9956 534k 387k 374k
+		// NB: End-patches are now keyed by their canonical orientation over all 8 D4 symmetries 
+		//     (rather than just the 4 flips), so stats files from before then should be regenerated
 	} else {
-		transitions.TrainingSynthetic_to_stats(steps, 200*1000) 
+		transitions.TrainingSynthetic_to_stats(steps, 200*1000, board_rule) 
 	}
 	
-	transitions.SaveCSV(fmt.Sprintf(TransitionCollectionFileStrFmt, steps))
+	transitions.SaveCSV(TransitionCollectionFile(steps, board_rule))
 }
 
 func main_create_stats_all(use_training_data bool) {
@@ -303,7 +759,7 @@ total 66520
 func main_read_stats(steps int) {
 	var transitions TransitionCollectionList
 	
-	transitions.LoadCSV(fmt.Sprintf(TransitionCollectionFileStrFmt, steps)) 
+	transitions.LoadCSV(TransitionCollectionFile(steps, board_rule)) 
 }
 
 func main_create_fake_training_data() {
@@ -335,8 +791,8 @@ func main_create_fake_training_data() {
 const currently_running_version int = 1020
 
 func main() {
-	cmd:= flag.String("cmd", "", "Required : {db|create|visualize|run|submit}")
-	cmd_type:= flag.String("type", "", "create:{fake_training_data|training_set_transitions|synthetic_transitions}, db:{test|insert_problems}, visualize:{data|ga}, submit:{kaggle|fakescore}")
+	cmd:= flag.String("cmd", "", "Required : {db|create|visualize|run|submit|analyze|export|check}")
+	cmd_type:= flag.String("type", "", "create:{fake_training_data|training_set_transitions|synthetic_transitions}, db:{test|insert_problems|import_model|migrate_binary|migrate_params}, visualize:{data|ga|pattern}, run:{ga|sat|chain|anneal|walk|islands}, submit:{kaggle|fakescore|probability}, analyze:{fate|census|orphans|predecessors|chain}, export:{rle|cells|lif|cnf}, check:{engines}")
 	
 	delta := flag.Int("delta", 0, "Number of steps between start and end")
 	seed  := flag.Int64("seed", 1, "Random seed to use")
@@ -345,11 +801,75 @@ func main() {
 	training_only := flag.Bool("training", false, "Act on training set (default=false, i.e. test set)")
 
 	count := flag.Int("count", 0, "Number of ids to process")
+	file := flag.String("file", "", "Pattern file to read (.rle, .cells or .lif) for visualize and run, or SAT solver output for db import_model")
+	distance := flag.Int("distance", 1, "Cells within this distance belong to the same object (1 = 8-connected), for analyze census")
+	limit := flag.Int("limit", 10000, "Stop after this many predecessors (0 = find them all), for analyze predecessors")
+	profile := flag.String("profile", "", "JSON file of GA parameters, with optional per-delta overrides (see profile.go)")
+	ga_overrides := flag.String("ga", "", "GA parameters that override the profile, e.g. pop_size=500,iter_max=4000")
+	budget := flag.Duration("budget", 0, "Time allowed for each problem, e.g. 30s (0 = the solver's own default), for run")
+	islands := flag.Int("islands", island_settings.islands, "Number of islands (0 = one per cpu), for run -type=islands")
+	topology := flag.String("topology", island_settings.topology.String(), "Which islands each one sends migrants to : {ring|full}, for run -type=islands")
+	migrate := flag.Int("migrate", island_settings.migrate_every, "Generations between island migrations (0 = never), for run -type=islands")
+	migrants := flag.Int("migrants", island_settings.migrants, "Individuals each island sends to each neighbour, for run -type=islands")
+	stall := flag.Int("stall", island_settings.stall, "Migrations without a better best before the islands give up (0 = never), for run -type=islands")
+	prior := flag.Int("prior", 0, "Votes for the per-cell probability map alongside the solutions (each solution gets 2), for submit")
 
+	width  := flag.Int("width",  board_width,  "Board width (Kaggle boards are 20x20)")
+	height := flag.Int("height", board_height, "Board height (Kaggle boards are 20x20)")
+	boundary := flag.String("boundary", "dead", "What lies beyond the board edges : {dead|torus|unknown}")
+	engine := flag.String("engine", "packed", "Iteration engine : {packed|adder|check}")
+	rule := flag.String("rule", "B3/S23", "Life-like rule, e.g. B3/S23 (Conway), B36/S23 (HighLife), B3678/S34678 (Day&Night)")
 	
 	flag.Parse()
 	//fmt.Printf("CMD = %s\n", *cmd)
 	
+	if *width<=0 || *height<=0 {
+		fmt.Println("Need positive '-width' and '-height'")
+		flag.Usage()
+		return
+	}
+	board_width, board_height = *width, *height
+	
+	if b, ok := ParseBoundary(*boundary); ok {
+		board_boundary = b
+	} else {
+		fmt.Printf("Unknown '-boundary=%s'\n", *boundary)
+		flag.Usage()
+		return
+	}
+	board_empty = NewBoard_BoolPacked(board_width, board_height) // Needs to match the runtime dimensions
+	
+	if e, ok := ParseEngine(*engine); ok {
+		board_engine = e
+	} else {
+		fmt.Printf("Unknown '-engine=%s'\n", *engine)
+		flag.Usage()
+		return
+	}
+	
+	if r, err := ParseRule(*rule); err == nil {
+		board_rule = r
+	} else {
+		fmt.Println("Error:", err)
+		flag.Usage()
+		return
+	}
+	if !board_rule.IsConway() {
+		fmt.Printf("Using rule %s\n", board_rule)
+	}
+	
+	if *profile!="" {
+		if err := LoadGAProfile(*profile); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+	if err := SetGAOverrides(*ga_overrides); err != nil {
+		fmt.Println("Error:", err)
+		flag.Usage()
+		return
+	}
+	
 	//rand.Seed(time.Now().UnixNano()) 
 	rand.Seed(*seed)
 	
@@ -371,6 +891,26 @@ func main() {
 			create_list_of_problems_in_db() // NB: This sets up the 'problems' table to want answers...
 		}
 		
+		/// ./reverse-gol -cmd=db -type=import_model -training=true -id=50 -file=cnf/train-50-delta1.model
+		if *cmd_type=="import_model" {
+			if *id<=0 || *file=="" {
+				fmt.Println("Need to specify '-id' and '-file'")
+				flag.Usage()
+				return
+			}
+			main_import_model(*training_only, *id, *file)
+		}
+		
+		/// ./reverse-gol -cmd=db -type=migrate_binary
+		if *cmd_type=="migrate_binary" {
+			migrate_solutions_to_binary() // Re-encodes the old '0'/'1' start boards
+		}
+		
+		/// ./reverse-gol -cmd=db -type=migrate_params
+		if *cmd_type=="migrate_params" {
+			migrate_solutions_add_params() // Somewhere to record the GA parameters of each solution
+		}
+		
 		//reset_all_currently_processing(-1)
 		
 		//probs := list_of_interesting_problems_from_db(1,5,true) // training 
@@ -446,6 +986,40 @@ func main() {
 			}
 			main_population_score(*training_only, *id)
 		}
+		
+		/// ./reverse-gol -cmd=visualize -type=pattern -file=patterns/glider.rle
+		if *cmd_type=="pattern" {
+			if *file=="" {
+				fmt.Println("Need to specify '-file'")
+				flag.Usage()
+				return
+			}
+			main_visualize_pattern(*file)
+		}
+	}
+	
+	if *cmd=="export" {
+		/// ./reverse-gol -cmd=export -type=rle -training=true -id=50 -count=10
+		/// ./reverse-gol -cmd=export -type=cells -training=true -id=50 -count=10
+		/// ./reverse-gol -cmd=export -type=lif -training=true -id=50 -count=10
+		if *cmd_type=="rle" || *cmd_type=="cells" || *cmd_type=="lif" {
+			if *id<=0 || *count<=0 {
+				fmt.Println("Need to specify '-id' and '-count'")
+				flag.Usage()
+				return
+			}
+			main_export_patterns(*training_only, *id, *count, *cmd_type)
+		}
+		
+		/// ./reverse-gol -cmd=export -type=cnf -training=true -id=50 -count=10
+		if *cmd_type=="cnf" {
+			if *id<=0 || *count<=0 {
+				fmt.Println("Need to specify '-id' and '-count'")
+				flag.Usage()
+				return
+			}
+			main_export_cnf(*training_only, *id, *count)
+		}
 	}
 
 	if *cmd=="run" {
@@ -463,6 +1037,46 @@ func main() {
 			flag.Usage()
 			return
 		}
+		
+		/// ./reverse-gol -cmd=run -type=sat -delta=1 -count=9977
+		/// ./reverse-gol -cmd=run -type=chain -delta=3 -count=9995
+		/// ./reverse-gol -cmd=run -type=anneal -delta=2 -count=10059
+		/// ./reverse-gol -cmd=run -type=walk -delta=1 -count=9977
+		/// ./reverse-gol -cmd=run -type=islands -delta=5 -count=10146
+		/// ./reverse-gol -cmd=run -type=ga -budget=30s -delta=2 -count=10059
+		/// ./reverse-gol -cmd=run -profile=profiles/example.json -ga=pop_size=2000 -delta=5 -count=10146
+		/// ./reverse-gol -cmd=run -ga=adaptive=1 -delta=3 -count=9995
+		/// ./reverse-gol -cmd=run -ga=dedup=1,crowding=1,immigrant_pct=5 -delta=4 -count=10146
+		if *cmd_type!="" { // Any registered solver (see solver.go), otherwise the GA
+			if err := use_solver(*cmd_type); err != nil {
+				fmt.Println("Error:", err)
+				flag.Usage()
+				return
+			}
+		}
+		solution_budget = *budget
+		
+		/// ./reverse-gol -cmd=run -type=islands -islands=8 -topology=full -migrate=10 -migrants=3 -stall=10 -ga=pop_size=500 -delta=5 -count=10146
+		if t, ok := ParseIslandTopology(*topology); ok {
+			island_settings.topology = t
+		} else {
+			fmt.Printf("Unknown '-topology=%s'\n", *topology)
+			flag.Usage()
+			return
+		}
+		if *islands<0 || *migrants<0 || *stall<0 {
+			fmt.Println("Need '-islands', '-migrants' and '-stall' to be 0 or more")
+			flag.Usage()
+			return
+		}
+		island_settings.islands, island_settings.migrate_every, island_settings.migrants, island_settings.stall = *islands, *migrate, *migrants, *stall
+		
+		/// ./reverse-gol -cmd=run -delta=1 -file=patterns/target.rle
+		if *file!="" {
+			main_solve_pattern(*file, *delta)
+			return
+		}
+		
 		if *count<=0 {
 			fmt.Println("Need to specify '-count=%d'")
 			flag.Usage()
@@ -471,6 +1085,9 @@ func main() {
 		if *training_only {
 			fmt.Println("Running on Training Data")
 		}
+		if !migrate_solutions_add_params() { // Otherwise every solution with GA parameters would fail to save
+			return
+		}
 		steps := *delta
 		// TODO : Revert back to original
 		//problem_count_requested:=*count // This may be truncated, if there are less available ids (some may be processing already)
@@ -480,6 +1097,8 @@ func main() {
 	}
 	
 	if *cmd=="submit" {
+		submission_prior_weight = *prior
+		
 		/// ./reverse-gol -cmd=submit -type=kaggle
 		if *cmd_type=="kaggle" {
 			// create submission
@@ -497,6 +1116,82 @@ func main() {
 			score := determine_kaggle_score("data/train_fake.csv", fname)
 			fmt.Printf("\nKaggle equivalent score should be : %8.6f\n", score)
 		}
+	
+		/// ./reverse-gol -cmd=submit -type=probability
+		/// ./reverse-gol -cmd=submit -type=probability -training=true
+		if *cmd_type=="probability" {
+			// baseline submission from the per-cell probabilities alone (no db, no GA)
+			fname := fmt.Sprintf("submissions/probability_%s.csv", time.Now().Format("2006-01-02_15-04"))
+			if *training_only {
+				fname = fmt.Sprintf("submissions/training-fake-probability_%s.csv", time.Now().Format("2006-01-02_15-04"))
+			}
+			create_probability_submission(fname, *training_only)
+			
+			if *training_only {
+				score := determine_kaggle_score("data/train_fake.csv", fname)
+				fmt.Printf("\nKaggle equivalent score should be : %8.6f\n", score)
+			}
+		}
+	}
+	
+	if *cmd=="analyze" {
+		/// ./reverse-gol -cmd=analyze -type=fate -training=true -id=1 -count=100
+		/// ./reverse-gol -cmd=analyze -type=fate -id=1 -count=100
+		if *cmd_type=="fate" {
+			if *id<=0 || *count<=0 {
+				fmt.Println("Need to specify '-id' and '-count'")
+				flag.Usage()
+				return
+			}
+			main_analyze_fates(*training_only, *id, *count)
+		}
+		
+		/// ./reverse-gol -cmd=analyze -type=orphans -training=true -id=1 -count=100
+		if *cmd_type=="orphans" {
+			if *id<=0 || *count<=0 {
+				fmt.Println("Need to specify '-id' and '-count'")
+				flag.Usage()
+				return
+			}
+			main_analyze_orphans(*training_only, *id, *count)
+		}
+		
+		/// ./reverse-gol -cmd=analyze -type=predecessors -training=true -id=1 -count=100 -limit=10000
+		if *cmd_type=="predecessors" {
+			if *id<=0 || *count<=0 || *limit<0 {
+				fmt.Println("Need to specify '-id' and '-count' (and a non-negative '-limit')")
+				flag.Usage()
+				return
+			}
+			main_analyze_predecessors(*training_only, *id, *count, *limit)
+		}
+		
+		/// ./reverse-gol -cmd=analyze -type=chain -training=true -id=50001 -count=20
+		if *cmd_type=="chain" {
+			if *id<=0 || *count<=0 {
+				fmt.Println("Need to specify '-id' and '-count'")
+				flag.Usage()
+				return
+			}
+			main_analyze_chain(*training_only, *id, *count)
+		}
+		
+		/// ./reverse-gol -cmd=analyze -type=census -training=true -id=1 -count=100 -distance=2
+		if *cmd_type=="census" {
+			if *id<=0 || *count<=0 || *distance<=0 {
+				fmt.Println("Need to specify '-id' and '-count' (and a positive '-distance')")
+				flag.Usage()
+				return
+			}
+			main_analyze_census(*training_only, *id, *count, *distance)
+		}
+	}
+	
+	if *cmd=="check" {
+		/// ./reverse-gol -cmd=check -type=engines
+		if *cmd_type=="engines" {
+			main_check_engines()
+		}
 	}
 	//fmt.Printf("Random #%3d\n", rand.Intn(1000))
 }
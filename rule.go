@@ -0,0 +1,96 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Rule is a Life-like (outer totalistic) rule, as in "B3/S23" notation
+type Rule struct {
+	birth, survive uint16 // bit n set => n live neighbours gives birth / survival
+
+	// Precomputed next state, indexed by the 3x3 window (top<<6 | mid<<3 | bot), so the centre cell is bit 4
+	next [512]bool
+}
+
+func NewRule(birth, survive uint16) *Rule {
+	r := &Rule{birth: birth, survive: survive}
+	for window := 0; window < 512; window++ {
+		cnt := uint(bits.OnesCount(uint(window) &^ (1 << 4)))
+		if window&(1<<4) != 0 {
+			r.next[window] = survive&(1<<cnt) != 0
+		} else {
+			r.next[window] = birth&(1<<cnt) != 0
+		}
+	}
+	return r
+}
+
+// Conway's original : exactly 3 neighbours gives birth, 2 or 3 neighbours survive
+var Rule_Conway = NewRule(1<<3, 1<<2|1<<3)
+
+// Default for new BoardIterators : Can be overridden at runtime (see -rule in main())
+var board_rule = Rule_Conway
+
+// ParseRule understands "B3/S23" (either order, any case) as well as the older "23/3" (survival/birth) notation
+func ParseRule(s string) (*Rule, error) {
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rule '%s' should look like 'B3/S23'", s)
+	}
+
+	var birth, survive uint16
+	for i, part := range parts {
+		target := &survive // "23/3" : survival comes first
+		if i == 1 {
+			target = &birth
+		}
+		if strings.HasPrefix(part, "B") {
+			target, part = &birth, part[1:]
+		} else if strings.HasPrefix(part, "S") {
+			target, part = &survive, part[1:]
+		}
+		for _, c := range part {
+			if c < '0' || c > '8' {
+				return nil, fmt.Errorf("rule '%s' has bad neighbour count '%c'", s, c)
+			}
+			*target |= 1 << uint(c-'0')
+		}
+	}
+	return NewRule(birth, survive), nil
+}
+
+// Is the cell alive at the next step, given its current state and count of live neighbours
+func (r *Rule) Alive(alive bool, cnt int) bool {
+	if alive {
+		return r.survive&(1<<uint(cnt)) != 0
+	}
+	return r.birth&(1<<uint(cnt)) != 0
+}
+
+func (r *Rule) IsConway() bool {
+	return r.birth == Rule_Conway.birth && r.survive == Rule_Conway.survive
+}
+
+// String returns the rule in "B3/S23" notation
+func (r *Rule) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('B')
+	for cnt := 0; cnt <= 8; cnt++ {
+		if r.birth&(1<<uint(cnt)) != 0 {
+			buf.WriteByte(byte('0' + cnt))
+		}
+	}
+	buf.WriteString("/S")
+	for cnt := 0; cnt <= 8; cnt++ {
+		if r.survive&(1<<uint(cnt)) != 0 {
+			buf.WriteByte(byte('0' + cnt))
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,386 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// "This start board becomes problem.end after problem.steps" as CNF : There's a variable for every
+// cell of every generation before the end (the start board is generation 0, and its cells are
+// variables 1..w*h in row order), plus auxiliary variables that count each cell's live neighbours.
+// Cells known in advance (the end board, and the dead cells beyond a Boundary_Dead edge) are
+// constants, and are simplified away as the clauses are made
+
+const (
+	cnf_true  = 1 << 30
+	cnf_false = -cnf_true
+)
+
+type LifeCNF struct {
+	w, h, steps int
+	n_vars      int
+	clauses     [][]int
+	cells       [][]int     // [generation][y*w+x] : Variable, or cnf_true/cnf_false
+	outside     map[int]int // Boundary_Unknown : Variables for the ring just beyond the edge, by (generation, x, y)
+	boundary    Boundary
+}
+
+func (c *LifeCNF) new_var() int {
+	c.n_vars++
+	return c.n_vars
+}
+
+// add a clause, simplifying away the constants
+func (c *LifeCNF) add(lits ...int) {
+	clause := make([]int, 0, len(lits))
+	for _, l := range lits {
+		if l == cnf_true {
+			return
+		}
+		if l != cnf_false {
+			clause = append(clause, l)
+		}
+	}
+	c.clauses = append(c.clauses, clause) // May be empty, i.e. unsatisfiable
+}
+
+func (c *LifeCNF) and2(a, b int) int {
+	switch {
+	case a == cnf_false || b == cnf_false:
+		return cnf_false
+	case a == cnf_true:
+		return b
+	case b == cnf_true:
+		return a
+	}
+	v := c.new_var()
+	c.add(-v, a)
+	c.add(-v, b)
+	c.add(v, -a, -b)
+	return v
+}
+
+func (c *LifeCNF) or2(a, b int) int {
+	return -c.and2(-a, -b)
+}
+
+// Cell (x,y) of generation g, looking beyond the edges as the boundary says
+func (c *LifeCNF) cell(g, x, y int) int {
+	if x >= 0 && x < c.w && y >= 0 && y < c.h {
+		return c.cells[g][y*c.w+x]
+	}
+	switch c.boundary {
+	case Boundary_Torus:
+		return c.cells[g][((y+c.h)%c.h)*c.w+(x+c.w)%c.w]
+	case Boundary_Unknown: // Anything could be out there, and it needn't follow the rule
+		key := (g*(c.h+2)+y+1)*(c.w+2) + x + 1
+		if v, ok := c.outside[key]; ok {
+			return v
+		}
+		v := c.new_var()
+		c.outside[key] = v
+		return v
+	}
+	return cnf_false
+}
+
+// Constrain next to be what the rule makes of the centre cell and its 8 neighbours
+func (c *LifeCNF) add_transition(centre int, neighbours []int, next int, rule *Rule) {
+	// Counts beyond the largest one the rule mentions all behave the same
+	max_count := 0
+	for n := 0; n <= 8; n++ {
+		if (rule.birth|rule.survive)&(1<<uint(n)) != 0 {
+			max_count = n
+		}
+	}
+	top := max_count + 1
+	if top > 8 {
+		top = 8
+	}
+
+	// Sequential counter : at_least[j] <=> at least j of the neighbours so far are alive
+	var at_least [10]int
+	at_least[0] = cnf_true
+	for j := 1; j < len(at_least); j++ {
+		at_least[j] = cnf_false
+	}
+	for _, n := range neighbours {
+		for j := top; j >= 1; j-- {
+			at_least[j] = c.or2(at_least[j], c.and2(at_least[j-1], n))
+		}
+	}
+
+	exactly := func(n int) int {
+		return c.and2(at_least[n], -at_least[n+1])
+	}
+	count_in := func(set uint16) int {
+		r := cnf_false
+		for n := 0; n <= max_count; n++ {
+			if set&(1<<uint(n)) != 0 {
+				r = c.or2(r, exactly(n))
+			}
+		}
+		return r
+	}
+	survive, born := count_in(rule.survive), count_in(rule.birth)
+
+	// next <=> (centre ? survive : born)
+	c.add(-centre, -survive, next)
+	c.add(-centre, survive, -next)
+	c.add(centre, -born, next)
+	c.add(centre, born, -next)
+}
+
+func NewLifeCNF(end *Board_BoolPacked, steps int, rule *Rule) *LifeCNF {
+	c := &LifeCNF{
+		w: end.w, h: end.h, steps: steps,
+		cells:    make([][]int, steps+1),
+		outside:  make(map[int]int),
+		boundary: end.boundary,
+	}
+	for g := 0; g < steps; g++ { // Start board first, so that its variables are 1..w*h
+		c.cells[g] = make([]int, c.w*c.h)
+		for i := range c.cells[g] {
+			c.cells[g][i] = c.new_var()
+		}
+	}
+	c.cells[steps] = make([]int, c.w*c.h)
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			c.cells[steps][y*c.w+x] = cnf_false
+			if end.isSet(x, y) {
+				c.cells[steps][y*c.w+x] = cnf_true
+			}
+		}
+	}
+
+	neighbours := make([]int, 8)
+	for g := 0; g < steps; g++ {
+		for y := 0; y < c.h; y++ {
+			for x := 0; x < c.w; x++ {
+				i := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						if dx != 0 || dy != 0 {
+							neighbours[i] = c.cell(g, x+dx, y+dy)
+							i++
+						}
+					}
+				}
+				c.add_transition(c.cell(g, x, y), neighbours, c.cell(g+1, x, y), rule)
+			}
+		}
+	}
+	return c
+}
+
+func (c *LifeCNF) LoadInto(s *SatSolver) {
+	for s.NumVars() < c.n_vars {
+		s.NewVar()
+	}
+	for _, clause := range c.clauses {
+		if !s.AddClause(clause...) {
+			return
+		}
+	}
+}
+
+// Board for generation g of a model (a function giving the value of each variable)
+func (c *LifeCNF) Board(g int, value func(v int) bool) *Board_BoolPacked {
+	f := NewBoard_BoolPacked(c.w, c.h)
+	f.boundary = c.boundary
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			v := c.cells[g][y*c.w+x]
+			f.Set(x, y, v == cnf_true || (v != cnf_false && value(v)))
+		}
+	}
+	return f
+}
+
+// SolveSAT looks for a start board that becomes problem.end exactly.
+// The board is nil unless the result is Sat_Satisfiable
+func (problem LifeProblem) SolveSAT(limit SatLimit) (*Board_BoolPacked, SatResult) {
+	cnf := NewLifeCNF(problem.end, problem.steps, problem.Rule())
+	s := NewSatSolver()
+	cnf.LoadInto(s)
+
+	t0 := time.Now()
+	result := s.Solve(limit)
+	fmt.Printf("problem[%d].steps=%d : SAT solver says %s in %.1fs (%s)\n",
+		problem.id, problem.steps, result, time.Since(t0).Seconds(), s.Stats())
+	if result != Sat_Satisfiable {
+		return nil, result
+	}
+	return cnf.Board(0, s.Value), result
+}
+
+// Solver_SAT (-type=sat on the run command) : Where the solver can't find an exact predecessor in time
+// (or there isn't one), the GA takes over for whatever is left of the budget
+type Solver_SAT struct{}
+
+func (Solver_SAT) Solve(problem LifeProblem, lps *LifeProblemSet, budget time.Duration, random *rand.Rand) *IndividualResult {
+	limit := sat_time_limit
+	if budget > 0 {
+		limit = budget
+	}
+	deadline := time.Now().Add(limit)
+	start, result := problem.SolveSAT(SatLimit{deadline: deadline})
+	if result != Sat_Satisfiable {
+		ga_deadline := time.Time{} // No budget : The GA runs its course
+		if budget > 0 {
+			ga_deadline = deadline
+		}
+		return create_solution_until(problem, lps, ga_deadline)
+	}
+	return solution_result(problem, start, lps.is_training)
+}
+
+// How long Solver_SAT gives the solver for each problem (unless there's a budget)
+var sat_time_limit = 60 * time.Second
+
+// Score a start board found some other way than the GA, so that it can be stored like a GA solution
+func solution_result(problem LifeProblem, start *Board_BoolPacked, is_training bool) *IndividualResult {
+	true_start_1s, mismatch_from_true_start := -999, -999
+	if is_training {
+		true_start_1s = problem.start.Popcount()
+		mismatch_from_true_start = start.CompareTo(problem.start, nil)
+	}
+	l := NewBoardIterator(start.w, start.h)
+	l.rule = problem.Rule()
+	l.current.CopyFrom(start)
+	l.Iterate(problem.steps)
+	mismatch_from_true_end := l.current.CompareTo_Masked(problem.end, nil, problem.end.KnownMask(problem.steps))
+
+	return &IndividualResult{
+		individual: &Individual{start: start, diff: NewBoard_BoolPacked(start.w, start.h), fitness: -mismatch_from_true_end},
+
+		mismatch_from_true_start_initial: mismatch_from_true_start,
+		mismatch_from_true_start_final:   mismatch_from_true_start,
+		mismatch_from_true_end_initial:   mismatch_from_true_end,
+		mismatch_from_true_end_final:     mismatch_from_true_end,
+
+		true_start_1s: true_start_1s,
+		true_end_1s:   problem.end.Popcount(),
+	}
+}
+
+// DIMACS CNF, for stand-alone solvers : The comments say which variable is which cell
+//   c board <w> <h> steps <k> rule <rule> boundary <boundary>
+//   c cell <var> <generation> <x> <y>     (generation 0 is the start board)
+func (c *LifeCNF) WriteDIMACS(out io.Writer, title string, rule *Rule) error {
+	buf := bufio.NewWriter(out)
+	fmt.Fprintf(buf, "c %s\n", title)
+	fmt.Fprintf(buf, "c board %d %d steps %d rule %s boundary %s\n", c.w, c.h, c.steps, rule, c.boundary)
+	for g := 0; g < c.steps; g++ {
+		for y := 0; y < c.h; y++ {
+			for x := 0; x < c.w; x++ {
+				fmt.Fprintf(buf, "c cell %d %d %d %d\n", c.cells[g][y*c.w+x], g, x, y)
+			}
+		}
+	}
+	fmt.Fprintf(buf, "p cnf %d %d\n", c.n_vars, len(c.clauses))
+	for _, clause := range c.clauses {
+		for _, l := range clause {
+			fmt.Fprintf(buf, "%d ", l)
+		}
+		buf.WriteString("0\n")
+	}
+	return buf.Flush()
+}
+
+func (c *LifeCNF) SaveDIMACS(filename string, title string, rule *Rule) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return c.WriteDIMACS(file, title, rule)
+}
+
+// LoadDIMACSStart reads a model (as printed by the usual solvers : "s SATISFIABLE" then "v 1 -2 ... 0",
+// or MiniSat's "SAT" then "1 -2 ... 0") and turns it into the start board, using the map in the .cnf file
+func LoadDIMACSStart(cnf_filename, model_filename string) (*Board_BoolPacked, error) {
+	model := make(map[int]bool)
+	text, err := ioutil.ReadFile(model_filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(text), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "c" {
+			continue
+		}
+		switch strings.ToUpper(strings.Join(fields, " ")) {
+		case "S SATISFIABLE", "SAT":
+			continue
+		case "S UNSATISFIABLE", "UNSAT":
+			return nil, fmt.Errorf("%s : Solver says there's no solution", model_filename)
+		case "S UNKNOWN", "INDET", "UNKNOWN":
+			return nil, fmt.Errorf("%s : Solver didn't finish", model_filename)
+		}
+		if fields[0] == "v" {
+			fields = fields[1:]
+		}
+		for _, field := range fields {
+			l, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s : '%s' isn't a literal", model_filename, field)
+			}
+			if l > 0 {
+				model[l] = true
+			} else if l < 0 {
+				model[-l] = false
+			}
+		}
+	}
+
+	cnf, err := os.Open(cnf_filename)
+	if err != nil {
+		return nil, err
+	}
+	defer cnf.Close()
+
+	var f *Board_BoolPacked
+	scanner := bufio.NewScanner(cnf)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "c" {
+			break // The map is all at the top
+		}
+		if len(fields) >= 4 && fields[1] == "board" {
+			w, _ := strconv.Atoi(fields[2])
+			h, _ := strconv.Atoi(fields[3])
+			f = NewBoard_BoolPacked(w, h)
+			if len(fields) >= 10 && fields[8] == "boundary" {
+				if b, ok := ParseBoundary(fields[9]); ok {
+					f.boundary = b
+				}
+			}
+		}
+		if len(fields) == 6 && fields[1] == "cell" && fields[3] == "0" && f != nil {
+			v, _ := strconv.Atoi(fields[2])
+			x, _ := strconv.Atoi(fields[4])
+			y, _ := strconv.Atoi(fields[5])
+			value, ok := model[v]
+			if !ok {
+				return nil, fmt.Errorf("%s : No value for variable %d", model_filename, v)
+			}
+			f.Set_safe(x, y, value)
+		}
+	}
+	if f == nil {
+		return nil, fmt.Errorf("%s : No 'c board' line, so this isn't one of ours", cnf_filename)
+	}
+	return f, scanner.Err()
+}
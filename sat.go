@@ -0,0 +1,569 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A small CDCL (conflict-driven clause learning) SAT solver, in the MiniSat mould :
+// two watched literals, VSIDS branching with phase saving, first-UIP learning,
+// Luby restarts and LBD-based clause deletion.
+// Variables are numbered from 1, and clauses are given as DIMACS-style ints (-v is 'not v')
+
+type SatResult int
+
+const (
+	Sat_Unknown SatResult = iota // Ran out of conflicts or time
+	Sat_Satisfiable
+	Sat_Unsatisfiable
+)
+
+func (r SatResult) String() string {
+	switch r {
+	case Sat_Satisfiable:
+		return "SAT"
+	case Sat_Unsatisfiable:
+		return "UNSAT"
+	}
+	return "UNKNOWN"
+}
+
+// Internally, literal l is 2*v+sign for 0-based variable v (sign=1 for negated)
+type sat_lit int32
+
+func (l sat_lit) v() int          { return int(l >> 1) }
+func (l sat_lit) negated() bool   { return l&1 != 0 }
+func (l sat_lit) not() sat_lit    { return l ^ 1 }
+func dimacs_to_lit(d int) sat_lit {
+	if d < 0 {
+		return sat_lit(2*(-d-1) + 1)
+	}
+	return sat_lit(2 * (d - 1))
+}
+
+type sat_clause struct {
+	lits     []sat_lit
+	learnt   bool
+	deleted  bool
+	lbd      int
+	activity float64
+}
+
+type SatSolver struct {
+	ok bool // false once the clauses are known to be unsatisfiable
+
+	clauses, learnts []*sat_clause
+	watches          [][]*sat_clause // by literal : clauses with that literal in position 0 or 1
+
+	assign []int8 // by variable : 0 unassigned, 1 true, -1 false
+	level  []int
+	reason []*sat_clause
+	phase  []bool // Last sign each variable had (saved across backtracking)
+	seen   []bool
+
+	trail     []sat_lit
+	trail_lim []int // trail position at the start of each decision level
+	qhead     int
+
+	activity []float64
+	var_inc  float64
+	order    sat_heap
+
+	clause_inc  float64
+	max_learnts float64
+
+	model []bool
+
+	conflicts, decisions, propagations int
+}
+
+func NewSatSolver() *SatSolver {
+	s := &SatSolver{ok: true, var_inc: 1, clause_inc: 1}
+	s.order.activity = &s.activity
+	return s
+}
+
+func (s *SatSolver) NumVars() int {
+	return len(s.assign)
+}
+
+// NewVar returns the (1-based) number of a fresh variable
+func (s *SatSolver) NewVar() int {
+	v := len(s.assign)
+	s.assign = append(s.assign, 0)
+	s.level = append(s.level, 0)
+	s.reason = append(s.reason, nil)
+	s.phase = append(s.phase, true) // Start off trying 'false' (Life boards are mostly empty)
+	s.seen = append(s.seen, false)
+	s.activity = append(s.activity, 0)
+	s.watches = append(s.watches, nil, nil)
+	s.order.insert(v)
+	return v + 1
+}
+
+func (s *SatSolver) value(l sat_lit) int8 {
+	a := s.assign[l.v()]
+	if l.negated() {
+		return -a
+	}
+	return a
+}
+
+func (s *SatSolver) decision_level() int {
+	return len(s.trail_lim)
+}
+
+// AddClause adds a clause of DIMACS literals (creating variables as needed).
+// Returns false if the problem has become trivially unsatisfiable
+func (s *SatSolver) AddClause(dimacs ...int) bool {
+	if !s.ok {
+		return false
+	}
+	lits := make([]sat_lit, 0, len(dimacs))
+	for _, d := range dimacs {
+		v := d
+		if v < 0 {
+			v = -v
+		}
+		for s.NumVars() < v {
+			s.NewVar()
+		}
+		lits = append(lits, dimacs_to_lit(d))
+	}
+
+	// Remove duplicates and false literals, and skip clauses that are already satisfied
+	sort.Slice(lits, func(i, j int) bool { return lits[i] < lits[j] })
+	j := 0
+	for i, l := range lits {
+		if s.value(l) == 1 || (i > 0 && l == lits[i-1].not()) {
+			return true
+		}
+		if s.value(l) == -1 || (i > 0 && l == lits[i-1]) {
+			continue
+		}
+		lits[j] = l
+		j++
+	}
+	lits = lits[:j]
+
+	switch len(lits) {
+	case 0:
+		s.ok = false
+	case 1:
+		s.enqueue(lits[0], nil)
+		s.ok = s.propagate() == nil
+	default:
+		c := &sat_clause{lits: lits}
+		s.attach(c)
+		s.clauses = append(s.clauses, c)
+	}
+	return s.ok
+}
+
+func (s *SatSolver) attach(c *sat_clause) {
+	s.watches[c.lits[0]] = append(s.watches[c.lits[0]], c)
+	s.watches[c.lits[1]] = append(s.watches[c.lits[1]], c)
+}
+
+func (s *SatSolver) enqueue(l sat_lit, from *sat_clause) {
+	v := l.v()
+	s.assign[v] = 1
+	if l.negated() {
+		s.assign[v] = -1
+	}
+	s.level[v] = s.decision_level()
+	s.reason[v] = from
+	s.trail = append(s.trail, l)
+}
+
+// Unit propagation : Returns the conflicting clause, if there is one
+func (s *SatSolver) propagate() *sat_clause {
+	for s.qhead < len(s.trail) {
+		false_lit := s.trail[s.qhead].not()
+		s.qhead++
+		s.propagations++
+
+		ws := s.watches[false_lit]
+		i, j := 0, 0
+		for i < len(ws) {
+			c := ws[i]
+			i++
+			if c.deleted {
+				continue // Dropped lazily
+			}
+			if c.lits[0] == false_lit { // Keep the false literal in position 1
+				c.lits[0], c.lits[1] = c.lits[1], c.lits[0]
+			}
+			if s.value(c.lits[0]) == 1 {
+				ws[j] = c
+				j++
+				continue
+			}
+
+			moved := false
+			for k := 2; k < len(c.lits); k++ {
+				if s.value(c.lits[k]) != -1 {
+					c.lits[1], c.lits[k] = c.lits[k], c.lits[1]
+					s.watches[c.lits[1]] = append(s.watches[c.lits[1]], c)
+					moved = true
+					break
+				}
+			}
+			if moved {
+				continue
+			}
+
+			ws[j] = c
+			j++
+			if s.value(c.lits[0]) == -1 { // Conflict
+				j += copy(ws[j:], ws[i:])
+				s.watches[false_lit] = ws[:j]
+				s.qhead = len(s.trail)
+				return c
+			}
+			s.enqueue(c.lits[0], c)
+		}
+		s.watches[false_lit] = ws[:j]
+	}
+	return nil
+}
+
+func (s *SatSolver) bump_var(v int) {
+	s.activity[v] += s.var_inc
+	if s.activity[v] > 1e100 {
+		for i := range s.activity {
+			s.activity[i] *= 1e-100
+		}
+		s.var_inc *= 1e-100
+	}
+	s.order.update(v)
+}
+
+func (s *SatSolver) bump_clause(c *sat_clause) {
+	c.activity += s.clause_inc
+	if c.activity > 1e20 {
+		for _, l := range s.learnts {
+			l.activity *= 1e-20
+		}
+		s.clause_inc *= 1e-20
+	}
+}
+
+// First-UIP conflict analysis : Returns the learnt clause (asserting literal first) and the level to go back to
+func (s *SatSolver) analyze(confl *sat_clause) ([]sat_lit, int) {
+	learnt := []sat_lit{0} // Room for the asserting literal
+	path_count := 0
+	p := sat_lit(-1)
+	index := len(s.trail) - 1
+
+	for {
+		if confl.learnt {
+			s.bump_clause(confl)
+		}
+		start := 0
+		if p != -1 {
+			start = 1 // lits[0] is p itself
+		}
+		for _, q := range confl.lits[start:] {
+			v := q.v()
+			if s.seen[v] || s.level[v] == 0 {
+				continue
+			}
+			s.bump_var(v)
+			s.seen[v] = true
+			if s.level[v] >= s.decision_level() {
+				path_count++
+			} else {
+				learnt = append(learnt, q)
+			}
+		}
+		for !s.seen[s.trail[index].v()] {
+			index--
+		}
+		p = s.trail[index]
+		index--
+		confl = s.reason[p.v()]
+		s.seen[p.v()] = false
+		path_count--
+		if path_count == 0 {
+			break
+		}
+	}
+	learnt[0] = p.not()
+
+	// Drop literals that are implied by the others (all of their reason is already in the clause)
+	kept := make([]sat_lit, 1, len(learnt))
+	kept[0] = learnt[0]
+	for _, q := range learnt[1:] {
+		r := s.reason[q.v()]
+		redundant := r != nil
+		if redundant {
+			for _, l := range r.lits[1:] {
+				if !s.seen[l.v()] && s.level[l.v()] > 0 {
+					redundant = false
+					break
+				}
+			}
+		}
+		if !redundant {
+			kept = append(kept, q)
+		}
+	}
+	for _, q := range learnt {
+		s.seen[q.v()] = false
+	}
+	learnt = kept
+
+	// Backtrack to the second-highest level in the clause (which goes in position 1, to be watched)
+	back_level := 0
+	for i := 1; i < len(learnt); i++ {
+		if s.level[learnt[i].v()] > back_level {
+			back_level = s.level[learnt[i].v()]
+			learnt[1], learnt[i] = learnt[i], learnt[1]
+		}
+	}
+	return learnt, back_level
+}
+
+// Literal Block Distance : Number of different decision levels in the clause (lower is better)
+func (s *SatSolver) lbd(lits []sat_lit) int {
+	levels := make(map[int]bool)
+	for _, l := range lits {
+		levels[s.level[l.v()]] = true
+	}
+	return len(levels)
+}
+
+func (s *SatSolver) backtrack(level int) {
+	if s.decision_level() <= level {
+		return
+	}
+	for i := len(s.trail) - 1; i >= s.trail_lim[level]; i-- {
+		v := s.trail[i].v()
+		s.phase[v] = s.trail[i].negated()
+		s.assign[v] = 0
+		s.reason[v] = nil
+		s.order.insert(v)
+	}
+	s.trail = s.trail[:s.trail_lim[level]]
+	s.trail_lim = s.trail_lim[:level]
+	s.qhead = len(s.trail)
+}
+
+// Throw away the less useful half of the learnt clauses (keeping the 'glue' ones, and any that are reasons)
+func (s *SatSolver) reduce_learnts() {
+	sort.Slice(s.learnts, func(i, j int) bool {
+		a, b := s.learnts[i], s.learnts[j]
+		if a.lbd != b.lbd {
+			return a.lbd < b.lbd
+		}
+		return a.activity > b.activity
+	})
+	kept := s.learnts[:0]
+	for i, c := range s.learnts {
+		locked := s.reason[c.lits[0].v()] == c && s.value(c.lits[0]) == 1
+		if i < len(s.learnts)/2 || c.lbd <= 2 || locked {
+			kept = append(kept, c)
+		} else {
+			c.deleted = true
+		}
+	}
+	s.learnts = kept
+}
+
+func (s *SatSolver) pick_branch() (sat_lit, bool) {
+	for !s.order.empty() {
+		v := s.order.pop()
+		if s.assign[v] == 0 {
+			l := sat_lit(2 * v)
+			if s.phase[v] {
+				l = l.not()
+			}
+			return l, true
+		}
+	}
+	return 0, false
+}
+
+// Luby sequence (1,1,2,1,1,2,4,1,...) for the restart intervals
+func luby(i int) int {
+	size, seq := 1, 0
+	for size < i+1 {
+		seq++
+		size = 2*size + 1
+	}
+	for size-1 != i {
+		size = (size - 1) >> 1
+		seq--
+		i = i % size
+	}
+	return 1 << uint(seq)
+}
+
+// Limits on a Solve() : Zero values mean no limit
+type SatLimit struct {
+	max_conflicts int
+	deadline      time.Time
+}
+
+func (s *SatSolver) Solve(limit SatLimit) SatResult {
+	if !s.ok {
+		return Sat_Unsatisfiable
+	}
+	if s.propagate() != nil {
+		s.ok = false
+		return Sat_Unsatisfiable
+	}
+	s.max_learnts = float64(len(s.clauses))/3 + 1000
+	restart := 0
+	restart_conflicts, conflicts_at_start := 100*luby(restart), s.conflicts
+
+	for {
+		confl := s.propagate()
+		if confl != nil {
+			s.conflicts++
+			if s.decision_level() == 0 {
+				s.ok = false
+				return Sat_Unsatisfiable
+			}
+			learnt, back_level := s.analyze(confl)
+			s.backtrack(back_level)
+			if len(learnt) == 1 {
+				s.enqueue(learnt[0], nil)
+			} else {
+				c := &sat_clause{lits: learnt, learnt: true, lbd: s.lbd(learnt)}
+				s.attach(c)
+				s.learnts = append(s.learnts, c)
+				s.bump_clause(c)
+				s.enqueue(learnt[0], c)
+			}
+			s.var_inc /= 0.95
+			s.clause_inc /= 0.999
+
+			if limit.max_conflicts > 0 && s.conflicts-conflicts_at_start >= limit.max_conflicts {
+				s.backtrack(0)
+				return Sat_Unknown
+			}
+			if !limit.deadline.IsZero() && s.conflicts%256 == 0 && time.Now().After(limit.deadline) {
+				s.backtrack(0)
+				return Sat_Unknown
+			}
+			if restart_conflicts--; restart_conflicts <= 0 {
+				restart++
+				restart_conflicts = 100 * luby(restart)
+				s.backtrack(0)
+			}
+			continue
+		}
+
+		if float64(len(s.learnts)-len(s.trail)) >= s.max_learnts {
+			s.reduce_learnts()
+			s.max_learnts *= 1.1
+		}
+
+		l, ok := s.pick_branch()
+		if !ok { // Everything assigned without conflict
+			s.model = make([]bool, len(s.assign))
+			for v, a := range s.assign {
+				s.model[v] = a == 1
+			}
+			s.backtrack(0)
+			return Sat_Satisfiable
+		}
+		s.decisions++
+		s.trail_lim = append(s.trail_lim, len(s.trail))
+		s.enqueue(l, nil)
+	}
+}
+
+// Value of (1-based) variable v in the model found by the last successful Solve()
+func (s *SatSolver) Value(v int) bool {
+	return s.model[v-1]
+}
+
+func (s *SatSolver) Stats() string {
+	return fmt.Sprintf("vars=%d, clauses=%d, learnts=%d, conflicts=%d, decisions=%d, propagations=%d",
+		s.NumVars(), len(s.clauses), len(s.learnts), s.conflicts, s.decisions, s.propagations)
+}
+
+// Max-heap of variables, ordered by activity
+type sat_heap struct {
+	heap     []int
+	index    []int // Position of each variable in heap, or -1
+	activity *[]float64
+}
+
+func (h *sat_heap) less(i, j int) bool {
+	return (*h.activity)[h.heap[i]] > (*h.activity)[h.heap[j]]
+}
+
+func (h *sat_heap) swap(i, j int) {
+	h.heap[i], h.heap[j] = h.heap[j], h.heap[i]
+	h.index[h.heap[i]] = i
+	h.index[h.heap[j]] = j
+}
+
+func (h *sat_heap) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(i, parent) {
+			break
+		}
+		h.swap(i, parent)
+		i = parent
+	}
+}
+
+func (h *sat_heap) down(i int) {
+	for {
+		child := 2*i + 1
+		if child >= len(h.heap) {
+			break
+		}
+		if child+1 < len(h.heap) && h.less(child+1, child) {
+			child++
+		}
+		if !h.less(child, i) {
+			break
+		}
+		h.swap(i, child)
+		i = child
+	}
+}
+
+func (h *sat_heap) empty() bool {
+	return len(h.heap) == 0
+}
+
+func (h *sat_heap) insert(v int) {
+	for len(h.index) <= v {
+		h.index = append(h.index, -1)
+	}
+	if h.index[v] >= 0 {
+		return
+	}
+	h.heap = append(h.heap, v)
+	h.index[v] = len(h.heap) - 1
+	h.up(len(h.heap) - 1)
+}
+
+func (h *sat_heap) update(v int) {
+	if v < len(h.index) && h.index[v] >= 0 {
+		h.up(h.index[v])
+	}
+}
+
+func (h *sat_heap) pop() int {
+	v := h.heap[0]
+	h.swap(0, len(h.heap)-1)
+	h.heap = h.heap[:len(h.heap)-1]
+	h.index[v] = -1
+	if len(h.heap) > 0 {
+		h.down(0)
+	}
+	return v
+}
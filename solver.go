@@ -0,0 +1,118 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Solver : Anything that can find a start board for a problem.  The result holds the start board
+// (in .individual.start) and the diagnostics that are saved alongside it, so every solver shares the
+// same queueing, db storage and submission pipeline.  A budget of 0 means the solver's own default,
+// and random is for the solver's own choices (it's seeded from the solution's seed).
+type Solver interface {
+	Solve(problem LifeProblem, lps *LifeProblemSet, budget time.Duration, random *rand.Rand) *IndividualResult
+}
+
+// A Solver that spreads each problem over all the cores itself, so problems should be handed out one at a time
+type MultiCoreSolver interface {
+	Solver
+	MultiCore() bool
+}
+
+var solver_registry = map[string]Solver{}
+
+func RegisterSolver(name string, s Solver) {
+	solver_registry[name] = s
+}
+
+func GetSolver(name string) (Solver, error) {
+	if s, ok := solver_registry[name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("Unknown solver '%s' : Choose from {%s}", name, strings.Join(SolverNames(), "|"))
+}
+
+func SolverNames() []string {
+	names := []string{}
+	for name := range solver_registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterSolver("ga", Solver_GA{})
+	RegisterSolver("sat", Solver_SAT{})
+	RegisterSolver("chain", Solver_Chain{})
+	RegisterSolver("anneal", Solver_Anneal{})
+	RegisterSolver("walk", Solver_Walk{})
+	RegisterSolver("islands", Solver_Islands{})
+}
+
+// How the run command solves each problem (see -type and -budget in main())
+var solution_solver Solver = Solver_GA{}
+var solution_solver_name = "ga"
+var solution_budget time.Duration
+
+func use_solver(name string) error {
+	s, err := GetSolver(name)
+	if err != nil {
+		return err
+	}
+	solution_solver, solution_solver_name = s, name
+	return nil
+}
+
+func solve_problem(problem LifeProblem, lps *LifeProblemSet, random *rand.Rand) *IndividualResult {
+	t0 := time.Now()
+	result := solution_solver.Solve(problem, lps, solution_budget, random)
+	result.elapsed = time.Since(t0)
+	fmt.Printf("problem[%d] : %s solver, mismatch vs end %d->%d, %d iterations (%.1fs)\n", problem.id, solution_solver_name,
+		result.mismatch_from_true_end_initial, result.mismatch_from_true_end_final, result.iter, result.elapsed.Seconds())
+	return result
+}
+
+// The time left in the budget, as a deadline (zero if there's no budget)
+func budget_deadline(budget time.Duration) time.Time {
+	if budget <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(budget)
+}
+
+// problem_orphans : The Garden-of-Eden parts of the target (only counting the known cells, if given),
+// which no solver can match however long it runs for
+func problem_orphans(problem LifeProblem, known *Board_BoolPacked) *OrphanReport {
+	orphans := problem.end.FindOrphans(problem.Rule(), known)
+	if orphans.orphans > 0 {
+		fmt.Printf("problem[%d] has %s\n", problem.id, orphans)
+	}
+	return orphans
+}
+
+// finish_result is the result for the start board a solver ended up with, given the mismatch vs end
+// it started from (its starting start board is the end board)
+func finish_result(problem LifeProblem, lps *LifeProblemSet, start *Board_BoolPacked, mismatch_from_true_end_initial int) *IndividualResult {
+	result := solution_result(problem, start, lps.is_training)
+	result.mismatch_from_true_end_initial = mismatch_from_true_end_initial
+	if lps.is_training {
+		result.mismatch_from_true_start_initial = problem.end.CompareTo(problem.start, nil)
+	}
+	return result
+}
+
+// The GA (see create_solution) : It still draws on the shared math/rand source, so random
+// just reseeds that (which keeps single-threaded runs repeatable)
+type Solver_GA struct{}
+
+func (Solver_GA) Solve(problem LifeProblem, lps *LifeProblemSet, budget time.Duration, random *rand.Rand) *IndividualResult {
+	rand.Seed(random.Int63())
+	return create_solution_until(problem, lps, budget_deadline(budget))
+}
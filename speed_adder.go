@@ -0,0 +1,152 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+)
+
+// Bit-parallel version of IterateRule : Rather than looking at each cell's window in turn,
+// the 8 neighbours of a whole word of cells are added up at once using half/full-adders
+// on shifted copies of the rows, giving the neighbour count as 4 bit-planes
+
+// Which implementation IterateRule uses
+type Engine int
+
+const (
+	Engine_Packed Engine = iota // Cell-by-cell window lookups (the original)
+	Engine_Adder                // Bit-parallel adders, a word of cells at a time
+	Engine_Check                // Run Engine_Adder, and complain if Iterate_Generic disagrees (slow!)
+)
+
+// Default engine : Can be overridden at runtime (see -engine in main())
+var board_engine Engine = Engine_Packed
+
+func ParseEngine(name string) (Engine, bool) {
+	switch name {
+	case "packed":
+		return Engine_Packed, true
+	case "adder":
+		return Engine_Adder, true
+	case "check":
+		return Engine_Check, true
+	}
+	return Engine_Packed, false
+}
+
+func half_adder(a, b uint64) (sum, carry uint64) {
+	return a ^ b, a & b
+}
+
+func full_adder(a, b, c uint64) (sum, carry uint64) {
+	t := a ^ b
+	return t ^ c, (a & b) | (c & t)
+}
+
+// Neighbour counts for 8 bit-vectors, as bit-planes (count = ones + 2*twos + 4*fours + 8*eights)
+func count_neighbours(n0, n1, n2, n3, n4, n5, n6, n7 uint64) (ones, twos, fours, eights uint64) {
+	s1, c1 := full_adder(n0, n1, n2)
+	s2, c2 := full_adder(n3, n4, n5)
+	s3, c3 := half_adder(n6, n7)
+	ones, c4 := full_adder(s1, s2, s3)
+
+	// c1..c4 all have weight 2
+	t1, k1 := full_adder(c1, c2, c3)
+	twos, k2 := half_adder(t1, c4)
+
+	// k1, k2 have weight 4 (and can only both be set when all 8 neighbours are)
+	fours, eights = half_adder(k1, k2)
+	return
+}
+
+// Bit-vector of the cells whose neighbour count is in the set (bit n set => count n)
+func count_in_set(set uint16, ones, twos, fours, eights uint64) uint64 {
+	acc := uint64(0)
+	for cnt := uint(0); cnt <= 8; cnt++ {
+		if set&(1<<cnt) == 0 {
+			continue
+		}
+		match := ^uint64(0)
+		for plane, bits := range [4]uint64{ones, twos, fours, eights} {
+			if cnt&(1<<uint(plane)) != 0 {
+				match &= bits
+			} else {
+				match &= ^bits
+			}
+		}
+		acc |= match
+	}
+	return acc
+}
+
+// The word k of row, shifted so that each bit sees its neighbour to the west (x-1)
+func row_west(row []uint64, k int) uint64 {
+	v := row[k] << 1
+	if k > 0 {
+		v |= row[k-1] >> 63
+	}
+	return v
+}
+
+// The word k of row, shifted so that each bit sees its neighbour to the east (x+1)
+func row_east(row []uint64, k int) uint64 {
+	v := row[k] >> 1
+	if k+1 < len(row) {
+		v |= row[k+1] << 63
+	}
+	return v
+}
+
+// Bits of word k that are real cells (i.e. not padding)
+func (f *Board_BoolPacked) cell_mask(k int) uint64 {
+	mask := ^uint64(0)
+	if k == 0 {
+		mask &^= 1 // Left padding bit
+	}
+	last := f.w + 1 // Right padding bit, and beyond
+	if last < (k+1)*64 {
+		mask &= (uint64(1) << uint(last-k*64)) - 1
+	}
+	return mask
+}
+
+func (f *Board_BoolPacked) iterate_adder(next *Board_BoolPacked, rule *Rule) { // OPTIMIZED FOR BoolPacked
+	conway := rule.IsConway()
+	for k := 0; k < f.stride; k++ {
+		next.s[k] = 0
+		next.s[(f.h+1)*f.stride+k] = 0
+	}
+	for r := 1; r <= f.h; r++ {
+		r_top, r_mid, r_bot := f.row(r-1), f.row(r), f.row(r+1)
+		for k := 0; k < f.stride; k++ {
+			ones, twos, fours, eights := count_neighbours(
+				row_west(r_top, k), r_top[k], row_east(r_top, k),
+				row_west(r_mid, k),           row_east(r_mid, k),
+				row_west(r_bot, k), r_bot[k], row_east(r_bot, k),
+			)
+			alive := r_mid[k]
+			if conway { // count==3, or count==2 and alive, all in one go
+				next.s[r*f.stride+k] = twos &^ fours &^ eights & (ones | alive) & f.cell_mask(k)
+				continue
+			}
+			born := count_in_set(rule.birth, ones, twos, fours, eights) &^ alive
+			survived := count_in_set(rule.survive, ones, twos, fours, eights) & alive
+			next.s[r*f.stride+k] = (born | survived) & f.cell_mask(k)
+		}
+	}
+}
+
+// Engine_Check : Use the adder result, but verify it cell-by-cell
+func (f *Board_BoolPacked) iterate_check(next *Board_BoolPacked, rule *Rule) {
+	f.iterate_adder(next, rule)
+
+	generic := NewBoard_BoolPacked(f.w, f.h)
+	f.clear_padding() // Iterate_Generic does its own padding
+	f.Iterate_Generic(generic, rule)
+	f.fill_padding()
+
+	if mismatch := next.CompareTo(generic, nil); mismatch > 0 {
+		panic(fmt.Sprintf("Engine check failed : %d cells differ for rule %s\n%v%v", mismatch, rule, next, generic))
+	}
+}
@@ -5,17 +5,57 @@ package main
 
 import (
 	"fmt"
+	"math/bits"
 	"math/rand"
 )
 
 
-const board_width  int =20
-const board_height int =20
+// These are the Kaggle defaults : They can be overridden at runtime (see -width and -height in main())
+var board_width  int =20
+var board_height int =20
+
+// What lies beyond the edges of a board
+type Boundary int
+
+const (
+	Boundary_Dead    Boundary = iota // Everything outside the board is dead (the Kaggle setting)
+	Boundary_Torus                   // Left/right and top/bottom edges wrap around
+	Boundary_Unknown                 // Outside is fixed but unknown : Treated as dead, but cells it can reach can't be scored
+)
+
+// Default for new boards : Can be overridden at runtime (see -boundary in main())
+var board_boundary Boundary = Boundary_Dead
+
+func ParseBoundary(name string) (Boundary, bool) {
+	switch name {
+	case "dead":
+		return Boundary_Dead, true
+	case "torus":
+		return Boundary_Torus, true
+	case "unknown":
+		return Boundary_Unknown, true
+	}
+	return Boundary_Dead, false
+}
+
+func (b Boundary) String() string {
+	switch b {
+	case Boundary_Torus:
+		return "torus"
+	case Boundary_Unknown:
+		return "unknown"
+	}
+	return "dead"
+}
 
 // Board represents a two-dimensional field of cells.
+// Each row is stored as 'stride' uint64 words, with one zeroed padding bit on either side 
+// of the row, and one zeroed padding row above and below the board
 type Board_BoolPacked struct {
-	s    []int32
-	h,w  int // Only used for GENERIC functions
+	s        []uint64
+	h,w      int
+	stride   int // Number of uint64 words per row (including padding bits)
+	boundary Boundary
 }
 
 var count_bits_array [512]byte
@@ -35,73 +75,163 @@ func build_count_bits_array() {
 
 var board_empty *Board_BoolPacked
 
-// NewBoard_BoolArray returns an empty field of the specified width and height.
+// NewBoard_BoolPacked returns an empty field of the specified width and height.
 func NewBoard_BoolPacked(w,h int) *Board_BoolPacked { // OPTIMIZED FOR BoolPacked
-	if board_width > 22 {
-		fmt.Print("TOO LARGE AN ARRAY for bottom 3 bytes of int32!\n")
-	}
-	
-	s := make([]int32, board_height+2) // Need padding before and after
-	return &Board_BoolPacked{s: s, h:board_height, w:board_width}
+	stride := (w+2+63)/64 // Need padding bit before and after each row
+	s := make([]uint64, (h+2)*stride) // Need padding row before and after
+	return &Board_BoolPacked{s: s, h:h, w:w, stride:stride, boundary:board_boundary}
 }
 
 func (dest *Board_BoolPacked) CopyFrom(src *Board_BoolPacked) { // OPTIMIZED FOR BoolPacked
-	dest.s = make([]int32, board_height+2)
-	for y := 0; y<board_height+2; y++ {
-		dest.s[y] = src.s[y]
+	dest.s = make([]uint64, len(src.s))
+	copy(dest.s, src.s)
+	dest.h, dest.w, dest.stride = src.h, src.w, src.stride
+	dest.boundary = src.boundary
+}
+
+// row returns the words for padded row r (i.e. r=0 is the top padding, r=h+1 is the bottom padding)
+func (f *Board_BoolPacked) row(r int) []uint64 {
+	return f.s[r*f.stride : (r+1)*f.stride]
+}
+
+func (f *Board_BoolPacked) rowIsEmpty(r int) bool {
+	for _, word := range f.row(r) {
+		if word != 0 {
+			return false
+		}
 	}
+	return true
 }
 
 // Set sets the state of the specified cell to the given value.
 func (f *Board_BoolPacked) Set(x, y int, b bool) { // OPTIMIZED FOR BoolPacked
 	//  The (+1,+1) offsets are to account for the zeroed-out borders
+	i := (y+1)*f.stride + (x+1)>>6
 	if b { //  This is a set=TRUE
-		f.s[y+1] |= (1 << uint(x+1))
+		f.s[i] |= (1 << uint((x+1)&63))
 	} else { //  This is a set=FALSE
-		f.s[y+1] &= ^(1 << uint(x+1))
+		f.s[i] &= ^(1 << uint((x+1)&63))
 	}
 }
 
 // Alive reports whether the specified cell is alive.
 // If the x or y coordinates are outside the field boundaries they are not wrapped
 func (f *Board_BoolPacked) isSet(x, y int) bool { // OPTIMIZED FOR BoolPacked
-	return (f.s[y+1] & (1 << uint(x+1))) != 0
+	return (f.s[(y+1)*f.stride + (x+1)>>6] & (1 << uint((x+1)&63))) != 0
 }
 
-// Update the state of the next field (next) in-place from the current field (f).
+// For Boundary_Torus, fill the padding ring with the cells from the opposite edges
+// so that the iteration code (which only looks at the padding) sees a wrapped universe
+func (f *Board_BoolPacked) fill_padding() {
+	if f.boundary != Boundary_Torus {
+		return
+	}
+	for r := 1; r <= f.h; r++ {
+		i := r*f.stride
+		if f.isSet(f.w-1, r-1) {
+			f.s[i] |= 1
+		}
+		if f.isSet(0, r-1) {
+			f.s[i + (f.w+1)>>6] |= 1 << uint((f.w+1)&63)
+		}
+	}
+	copy(f.row(0), f.row(f.h))
+	copy(f.row(f.h+1), f.row(1))
+}
+
+// Put the padding ring back to zero (CompareTo and friends rely on it)
+func (f *Board_BoolPacked) clear_padding() {
+	if f.boundary != Boundary_Torus {
+		return
+	}
+	for r := 1; r <= f.h; r++ {
+		i := r*f.stride
+		f.s[i] &^= 1
+		f.s[i + (f.w+1)>>6] &^= 1 << uint((f.w+1)&63)
+	}
+	for i := range f.row(0) {
+		f.s[i] = 0
+		f.s[(f.h+1)*f.stride+i] = 0
+	}
+}
+
+// bits3 returns the 3 bits centered on padded column p (i.e. x=p-1) of padded row r, as 0b(right,centre,left)
+func (f *Board_BoolPacked) bits3(r, p int) uint64 {
+	i := r*f.stride
+	lo := p-1
+	wi, bi := lo>>6, uint(lo&63)
+	v := f.s[i+wi] >> bi
+	if bi > 61 { // Straddles a word boundary
+		v |= f.s[i+wi+1] << (64-bi)
+	}
+	return v & 7
+}
+
+// Update the state of the next field (next) in-place from the current field (f), using the default rule
 func (f *Board_BoolPacked) Iterate(next *Board_BoolPacked) { // OPTIMIZED FOR BoolPacked
-	// This is done rather over-efficiently...
+	f.IterateRule(next, board_rule)
+}
 
-	// These are constants - the game bits pass over them
-	top_filter := int32(7) //  111
-	mid_filter := int32(5) //  101
-	bot_filter := int32(7) //  111
+// Update the state of the next field (next) in-place from the current field (f).
+func (f *Board_BoolPacked) IterateRule(next *Board_BoolPacked, rule *Rule) { // OPTIMIZED FOR BoolPacked
+	next.boundary = f.boundary
+	f.fill_padding()
+	defer f.clear_padding()
+	
+	switch board_engine {
+	case Engine_Adder:
+		f.iterate_adder(next, rule)
+		return
+	case Engine_Check:
+		f.iterate_check(next, rule)
+		return
+	}
+	
+	if f.stride == 1 {
+		f.iterate_single_word(next, rule)
+		return
+	}
+	// Multi-word rows : Pull out the 3x3 window around each cell
+	for i := range next.s {
+		next.s[i] = 0
+	}
+	for r := 1; r <= f.h; r++ {
+		for c := 1; c <= f.w; c++ {
+			if rule.next[(f.bits3(r-1, c)<<6)|(f.bits3(r, c)<<3)|f.bits3(r+1, c)] {
+				next.s[r*f.stride + c>>6] |= 1 << uint(c&63)
+			}
+		}
+	}
+}
 
-	current_filter := int32(2) //  010
+// This is the original (fast) version, for rows that fit within a single word (w<=62)
+func (f *Board_BoolPacked) iterate_single_word(next *Board_BoolPacked, rule *Rule) { // OPTIMIZED FOR BoolPacked
+	// This is done rather over-efficiently...
+
+	// This is a constant - the game bits pass over it
+	window_filter := uint64(7) //  111
 
 	next.s[0] = 0
-	for r := 1; r <= board_height; r++ {
+	for r := 1; r <= f.h; r++ {
 		r_top := f.s[r-1]
 		r_mid := f.s[r]
 		r_bot := f.s[r+1]
 
-		acc := int32(0)
-		p := int32(2) // Start in the middle row, one column in (000000010b)
-
-		for c := 1; c <= board_width; c++ {
-			cnt := count_bits_array[((r_top&top_filter)<<6)|
-									((r_mid&mid_filter)<<3)|
-									((r_bot&bot_filter))    ]
+		acc := uint64(0)
+		p := uint64(2) // Start in the middle row, one column in (000000010b)
 
+		for c := 1; c <= f.w; c++ {
 			// if 1==1 { acc |= p }  // Check bit-twiddling bounds
 
-			// Return next state according to the game rules:
+			// Return next state according to the game rules, which (for Conway) are :
 			//  exactly 3 neighbors: on,
 			//  exactly 2 neighbors: maintain current state,
 			//  otherwise: off.
 			//  return alive == 3 || alive == 2 && f.Alive(x, y)
-
-			if (cnt == 3) || (cnt == 2 && ((r_mid&current_filter) != 0)) {
+			
+			if rule.next[((r_top&window_filter)<<6)|
+						 ((r_mid&window_filter)<<3)|
+						 ((r_bot&window_filter))    ] {
 				acc |= p
 			}
 
@@ -115,22 +245,56 @@ func (f *Board_BoolPacked) Iterate(next *Board_BoolPacked) { // OPTIMIZED FOR Bo
 		}
 		next.s[r] = acc
 	}
-	next.s[board_height+1] = 0
+	next.s[f.h+1] = 0
+}
+
+// Cells that can be scored after 'steps' iterations : For Boundary_Unknown, the unknown outside 
+// spreads in one cell per step, so only cells at least 'steps' from every edge are known
+// Returns nil (i.e. everything is known) for the other boundaries
+func (f *Board_BoolPacked) KnownMask(steps int) *Board_BoolPacked {
+	if f.boundary != Boundary_Unknown {
+		return nil
+	}
+	// Everything, shifted in from each corner by 'steps' (cells shifted off the edge are dropped)
+	mask := NewBoard_BoolPacked(f.w, f.h)
+	mask.boundary = f.boundary // i.e. not wrapping
+	mask.Not(mask)
+	shifted := NewBoard_BoolPacked(f.w, f.h)
+	mask.And(mask, shifted.Shift(mask, steps, steps))
+	mask.And(mask, shifted.Shift(mask, -steps, -steps))
+	return mask
 }
 
 func (attempt *Board_BoolPacked) CompareTo(target *Board_BoolPacked, diff *Board_BoolPacked) int { // OPTIMIZED FOR BoolPacked
+	// Boards must have the same dimensions (board_empty is only suitable for board_width x board_height boards)
+	r := 0
+	match := uint64(0)
+	for i := attempt.stride; i < (attempt.h+1)*attempt.stride; i++ {
+		match = attempt.s[i] ^ target.s[i] // Padding bits are always zero, so only count real cells
+		if match>0 {
+			r += bits.OnesCount64(match)
+		}
+		if diff != nil {
+			diff.s[i]=match
+		}
+	}
+	return r
+}
+
+// As CompareTo, but only counting (and recording in diff) the cells that are set in mask
+func (attempt *Board_BoolPacked) CompareTo_Masked(target *Board_BoolPacked, diff *Board_BoolPacked, mask *Board_BoolPacked) int { // OPTIMIZED FOR BoolPacked
+	if mask == nil {
+		return attempt.CompareTo(target, diff)
+	}
 	r := 0
-	match := int32(0)
-	lowest_byte := int32(0xff)
-	for y := 1; y<=board_height; y++ {
-		match = attempt.s[y] ^ target.s[y] // This covers all of lower 24 bits, which is what we care about
+	match := uint64(0)
+	for i := attempt.stride; i < (attempt.h+1)*attempt.stride; i++ {
+		match = (attempt.s[i] ^ target.s[i]) & mask.s[i]
 		if match>0 {
-			r += int(count_bits_array[(match>>0) & lowest_byte] + 
-					 count_bits_array[(match>>8) & lowest_byte] + 
-					 count_bits_array[(match>>16) & lowest_byte])
+			r += bits.OnesCount64(match)
 		}
 		if diff != nil {
-			diff.s[y]=match
+			diff.s[i]=match
 		}
 	}
 	return r
@@ -148,9 +312,18 @@ func (f *Board_BoolPacked) MutateFlipBits(count int) {
 	}
 }
 
-func CoordWithinRadius(origin int, dim int, radius int) int {
+// shared_random draws on the shared math/rand source (so rand.Seed still decides what it does) : It's for
+// callers that don't have a *rand.Rand of their own
+var shared_random = rand.New(shared_source{})
+
+type shared_source struct{}
+
+func (shared_source) Int63() int64 { return rand.Int63() }
+func (shared_source) Seed(seed int64) { rand.Seed(seed) }
+
+func CoordWithinRadius(origin int, dim int, radius int, random *rand.Rand) int {
 	q :=-1
-	for ; (q<0 || q>=dim); q = origin+rand.Intn(radius*2+1)-radius {
+	for ; (q<0 || q>=dim); q = origin+random.Intn(radius*2+1)-radius {
 	}
 	return q
 }
@@ -164,8 +337,8 @@ func (f *Board_BoolPacked) MutateRadiusBits_SwitchARoo(another_mutation_pct, rad
 		}
 			
 		// and another within L1(radius) of it
-		dst_x := CoordWithinRadius(src_x, f.w, radius)
-		dst_y := CoordWithinRadius(src_y, f.h, radius)
+		dst_x := CoordWithinRadius(src_x, f.w, radius, shared_random)
+		dst_y := CoordWithinRadius(src_y, f.h, radius, shared_random)
 
 		src_isSet := f.isSet(src_x, src_y)
 		// Switch-a-roo
@@ -177,37 +350,32 @@ func (f *Board_BoolPacked) MutateRadiusBits_SwitchARoo(another_mutation_pct, rad
 func (f *Board_BoolPacked) MutateRadiusBits(another_mutation_pct, radius int) {
 	// Pick a random location
 	src_x, src_y := rand.Intn(f.w), rand.Intn(f.h)
+	block := NewBoard_BoolPacked(f.w, f.h)
 	for {
 		// Pick an L1 radius
 		r_up := rand.Intn(radius)
 		r_down := rand.Intn(radius)
-		for x:=src_x-r_down; x<=src_x+r_up; x++ {
-			for y:=src_y-r_down; y<=src_y+r_up; y++ {
-				if 0<=x && x<f.w && 0<=y && y<f.h {
-					f.Set(x, y, f.isSet(x, y) != true)
-				}
-			}
-		}
+		block.SetBlock(src_x, src_y, r_down, r_up)
+		f.Xor(f, block) // Flip the whole block
 		if rand.Intn(100)>another_mutation_pct {
 			break
 		}
 	}
 }
 
-func (mask *Board_BoolPacked) RandomBitPosition() (int, int) { // OPTIMIZED FOR BoolPacked
+func (mask *Board_BoolPacked) RandomBitPosition(random *rand.Rand) (int, int) { // OPTIMIZED FOR BoolPacked
 	// This isn't really a uniform picker amongst mask bits, but it makes an effort to be fast...
 	// Pick a random row, and find the first line there (or after) that has a non-zero in it
-	y := rand.Intn(board_height)
-	for cnt := board_height; (mask.s[y+1]==0) && cnt>0; cnt-- {
+	y := random.Intn(mask.h)
+	for cnt := mask.h; mask.rowIsEmpty(y+1) && cnt>0; cnt-- {
 		//fmt.Printf("MutateMask moving to next line %2d (count=%2d)\n", y, cnt)
 		y++
-		if y>=board_height {
+		if y>=mask.h {
 			//fmt.Printf("MutateMask wraparound after line %2d\n", y)
 			y=0
 		}
 	}
-	mask_row := mask.s[y+1]
-	if mask_row==0 {
+	if mask.rowIsEmpty(y+1) {
 		// We looped around : No mask>0 => No mask to be found.  i.e. no mutation to do
 		//fmt.Printf("MutateMask no diffs : Perfect! on line %d\n")
 		//fmt.Println(mask)
@@ -215,25 +383,25 @@ func (mask *Board_BoolPacked) RandomBitPosition() (int, int) { // OPTIMIZED FOR
 	}
 	
 	// Pick a random column
-	x := rand.Intn(board_width)
-	for cnt := board_width; ((mask_row & (1<<uint(x+1)))==0) && cnt>0; cnt-- {
+	x := random.Intn(mask.w)
+	for cnt := mask.w; !mask.isSet(x,y) && cnt>0; cnt-- {
 		x++
-		if x>=board_width {
+		if x>=mask.w {
 			x=0
 		}
 	}
 	
 	// Have found an x,y
 	if mask.isSet(x,y) != true {
-		fmt.Printf("MutateMask bit-twiddle failure %22b @ %2d=%22b %d\n", mask_row, x+1)
+		fmt.Printf("MutateMask bit-twiddle failure %v @ %2d\n", mask.row(y+1), x+1)
 		return -999,-999
 	}
 	return x,y
 }
 
-func (f *Board_BoolPacked) MutateMask(mask *Board_BoolPacked, another_mutation_pct, radius int) { // OPTIMIZED FOR BoolPacked
+func (f *Board_BoolPacked) MutateMask(mask *Board_BoolPacked, another_mutation_pct, radius int, random *rand.Rand) { // OPTIMIZED FOR BoolPacked
 	for {
-		x,y := mask.RandomBitPosition()
+		x,y := mask.RandomBitPosition(random)
 		
 		if x<0 || y<0 {
 			break
@@ -241,12 +409,12 @@ func (f *Board_BoolPacked) MutateMask(mask *Board_BoolPacked, another_mutation_p
 		
 		//f.Set(x,y, f.isSet(x,y)==false) // Flip the bit which corresponds to the diff
 		
-		x_offset := CoordWithinRadius(x, board_width, radius)
-		y_offset := CoordWithinRadius(y, board_height, radius)
+		x_offset := CoordWithinRadius(x, f.w, radius, random)
+		y_offset := CoordWithinRadius(y, f.h, radius, random)
 		f.Set(x_offset,y_offset, f.isSet(x_offset,y_offset)==false) // Flip the bit which corresponds to the diff+/-a radius distance
 		
 		//fmt.Printf("MutateMask flip bit (%2d,%2d)\n", x,y)
-		if rand.Intn(100)>another_mutation_pct {
+		if random.Intn(100)>another_mutation_pct {
 			break
 		}
 		//fmt.Printf("MutateMask round again\n")
@@ -256,13 +424,11 @@ func (f *Board_BoolPacked) MutateMask(mask *Board_BoolPacked, another_mutation_p
 
 
 func (offspring *Board_BoolPacked) CrossoverFrom_Horizontal(p1, p2 *Board_BoolPacked) { // OPTIMIZED FOR BoolPacked
-	offspring.s = make([]int32, board_height+2)
-	cross := rand.Intn(board_height+2)
-	for y := 0; y<board_height+2; y++ {
+	offspring.CopyFrom(p2)
+	cross := rand.Intn(p1.h+2)
+	for y := 0; y<p1.h+2; y++ {
 		if false && y<cross {
-			offspring.s[y] = p1.s[y]
-		} else {
-			offspring.s[y] = p2.s[y]
+			copy(offspring.row(y), p1.row(y))
 		}
 	}
 /*
@@ -276,25 +442,52 @@ func (offspring *Board_BoolPacked) CrossoverFrom_Horizontal(p1, p2 *Board_BoolPa
 */
 }
 
-func (offspring *Board_BoolPacked) CrossoverFrom(p1, p2 *Board_BoolPacked) {
-	offspring.CopyFrom(p1) // Grab p1 ASAP
-	
+// SetBlock clears f, and sets the square of cells from (x-r_down,y-r_down) to (x+r_up,y+r_up), cut off at the edges
+func (f *Board_BoolPacked) SetBlock(x, y, r_down, r_up int) { // OPTIMIZED FOR BoolPacked
+	for i := range f.s {
+		f.s[i] = 0
+	}
+	x_lo, x_hi := x-r_down, x+r_up
+	if x_lo<0 {
+		x_lo = 0
+	}
+	if x_hi>f.w-1 {
+		x_hi = f.w-1
+	}
+	lo, hi := x_lo+1, x_hi+1 // Bit positions, after the padding bit
+	for r := y-r_down; r<=y+r_up; r++ {
+		if r<0 || r>=f.h {
+			continue
+		}
+		row := f.row(r+1)
+		for k := lo>>6; k<=hi>>6; k++ {
+			a, b := lo-k*64, hi-k*64 // The span's bits within word k
+			if a<0 {
+				a = 0
+			}
+			if b>63 {
+				b = 63
+			}
+			row[k] = (^uint64(0) >> uint(63-(b-a))) << uint(a)
+		}
+	}
+}
+
+// CrossoverFrom is p1, but with a small random block from p2.  block is scratch space, the same size as p1
+func (offspring *Board_BoolPacked) CrossoverFrom(p1, p2, block *Board_BoolPacked, random *rand.Rand) {
 	// Pick a random location
-	src_x, src_y := rand.Intn(offspring.w), rand.Intn(offspring.h)
+	src_x, src_y := random.Intn(p1.w), random.Intn(p1.h)
 	
 	radius := 5
 	// Pick an L1 radius
-	r_up := rand.Intn(radius)
-	r_down := rand.Intn(radius)
+	r_up := random.Intn(radius)
+	r_down := random.Intn(radius)
 	
-	// Copy the rectangular blog from p2
-	for x:=src_x-r_down; x<=src_x+r_up; x++ {
-		for y:=src_y-r_down; y<=src_y+r_up; y++ {
-			if 0<=x && x<offspring.w && 0<=y && y<offspring.h {
-				offspring.Set(x, y, p2.isSet(x, y))
-			}
-		}
-	}
+	// Copy the rectangular blob from p2, and the rest from p1 : p1 ^ ((p1 ^ p2) & block)
+	block.SetBlock(src_x, src_y, r_down, r_up)
+	offspring.Xor(p1, p2)
+	offspring.And(offspring, block)
+	offspring.Xor(offspring, p1)
 }
 
 
@@ -0,0 +1,159 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"math/bits"
+)
+
+// BoardBatch simulates up to 64 boards (of the same size) at once, in 'bit-sliced' form :
+// Each cell is a uint64, where bit i is the state of that cell on board i.
+// The neighbour counting is then the same adder logic as iterate_adder, but across boards rather than along a row
+const BoardBatchSize int = 64
+
+type BoardBatch struct {
+	w, h     int
+	n        int      // Number of boards currently loaded
+	cells    []uint64 // (h+2)*(w+2), including a padding ring, at (y+1)*(w+2)+(x+1)
+	temp     []uint64
+	boundary Boundary
+	rule     *Rule
+}
+
+func NewBoardBatch(w, h int, rule *Rule) *BoardBatch {
+	return &BoardBatch{
+		w: w, h: h,
+		cells: make([]uint64, (h+2)*(w+2)),
+		temp:  make([]uint64, (h+2)*(w+2)),
+		boundary: board_boundary,
+		rule: rule,
+	}
+}
+
+func (bb *BoardBatch) index(x, y int) int {
+	return (y+1)*(bb.w+2) + (x+1)
+}
+
+// Transpose the boards into the batch (boards[i] goes into bit i)
+func (bb *BoardBatch) Load(boards []*Board_BoolPacked) {
+	if len(boards) > BoardBatchSize {
+		boards = boards[:BoardBatchSize]
+	}
+	for i := range bb.cells {
+		bb.cells[i] = 0
+	}
+	bb.n = len(boards)
+	if bb.n > 0 {
+		bb.boundary = boards[0].boundary
+	}
+	for i, f := range boards {
+		bit := uint64(1) << uint(i)
+		for y := 0; y < f.h; y++ {
+			for k, word := range f.row(y+1) {
+				for word != 0 { // Only visit the bits that are set
+					p := k*64 + bits.TrailingZeros64(word)
+					word &= word - 1
+					bb.cells[bb.index(p-1, y)] |= bit
+				}
+			}
+		}
+	}
+}
+
+// Copy board i back out of the batch
+func (bb *BoardBatch) Board(i int, dest *Board_BoolPacked) {
+	bit := uint64(1) << uint(i)
+	for y := 0; y < bb.h; y++ {
+		for x := 0; x < bb.w; x++ {
+			dest.Set(x, y, bb.cells[bb.index(x, y)]&bit != 0)
+		}
+	}
+}
+
+// Bits that correspond to loaded boards
+func (bb *BoardBatch) loaded() uint64 {
+	if bb.n >= BoardBatchSize {
+		return ^uint64(0)
+	}
+	return (uint64(1) << uint(bb.n)) - 1
+}
+
+// For Boundary_Torus, copy the opposite edges into the padding ring (otherwise it stays dead)
+func (bb *BoardBatch) fill_padding() {
+	if bb.boundary != Boundary_Torus {
+		return
+	}
+	c := bb.cells
+	for y := 0; y < bb.h; y++ {
+		c[bb.index(-1, y)] = c[bb.index(bb.w-1, y)]
+		c[bb.index(bb.w, y)] = c[bb.index(0, y)]
+	}
+	for x := -1; x <= bb.w; x++ {
+		c[bb.index(x, -1)] = c[bb.index(x, bb.h-1)]
+		c[bb.index(x, bb.h)] = c[bb.index(x, 0)]
+	}
+}
+
+// Advance all the loaded boards n steps
+func (bb *BoardBatch) Iterate(n int) {
+	stride := bb.w + 2
+	conway := bb.rule.IsConway()
+	for step := 0; step < n; step++ {
+		bb.fill_padding()
+		c, next := bb.cells, bb.temp
+		for y := 1; y <= bb.h; y++ {
+			for i := y*stride + 1; i <= y*stride+bb.w; i++ {
+				ones, twos, fours, eights := count_neighbours(
+					c[i-stride-1], c[i-stride], c[i-stride+1],
+					c[i-1],                     c[i+1],
+					c[i+stride-1], c[i+stride], c[i+stride+1],
+				)
+				alive := c[i]
+				if conway {
+					next[i] = twos &^ fours &^ eights & (ones | alive)
+					continue
+				}
+				born := count_in_set(bb.rule.birth, ones, twos, fours, eights) &^ alive
+				survived := count_in_set(bb.rule.survive, ones, twos, fours, eights) & alive
+				next[i] = born | survived
+			}
+		}
+		bb.cells, bb.temp = next, c
+	}
+}
+
+// Mismatch count for each loaded board vs target (only counting cells set in mask, if it's not nil),
+// with the differences written into diffs[i] (if given)
+func (bb *BoardBatch) CompareTo_Masked(target *Board_BoolPacked, diffs []*Board_BoolPacked, mask *Board_BoolPacked) []int {
+	mismatch := make([]int, bb.n)
+	for i := 0; i < bb.n && i < len(diffs); i++ {
+		if diffs[i] != nil {
+			for j := range diffs[i].s {
+				diffs[i].s[j] = 0
+			}
+		}
+	}
+	loaded := bb.loaded()
+	for y := 0; y < bb.h; y++ {
+		for x := 0; x < bb.w; x++ {
+			if mask != nil && !mask.isSet(x, y) {
+				continue
+			}
+			expected := uint64(0)
+			if target.isSet(x, y) {
+				expected = ^uint64(0)
+			}
+			wrong := (bb.cells[bb.index(x, y)] ^ expected) & loaded
+			for wrong != 0 {
+				i := bits.TrailingZeros64(wrong)
+				wrong &= wrong - 1
+				mismatch[i]++
+				if i < len(diffs) && diffs[i] != nil {
+					diffs[i].Set(x, y, true)
+				}
+			}
+		}
+	}
+	return mismatch
+}
@@ -12,6 +12,7 @@ import (
 	"bytes"
 	"math/rand"
 	"sort"
+	"strings"
 )
 
 
@@ -97,48 +98,86 @@ func (p Patch) Flip_LR() Patch {
 	return Patch(q)
 }
 
+// Transpose swaps rows and columns (i.e. reflects about the main diagonal)
+func (p Patch) Transpose() Patch {
+	var q Patch=0
+	for y:=0; y<5; y++ {
+		for x:=0; x<5; x++ {
+			if p.isSet(x,y) {
+				q |= 1<<uint((4-y)+5*(4-x))
+			}
+		}
+	}
+	return q
+}
+
+// One of the 8 symmetries of the square (the dihedral group D4), all of which Life is invariant under :
+// Applied as transpose first, then flip_ud, then flip_lr  (rotations come from transpose+flip combinations)
+type Orientation struct {
+	transpose, flip_ud, flip_lr bool
+}
+
+var Orientations_D4 = [8]Orientation{
+	{false, false, false}, {false, true, false}, {false, false, true}, {false, true, true},
+	{true,  false, false}, {true,  true, false}, {true,  false, true}, {true,  true, true},
+}
+
+func (o Orientation) Apply(p Patch) Patch {
+	if o.transpose {
+		p = p.Transpose()
+	}
+	if o.flip_ud {
+		p = p.Flip_UD()
+	}
+	if o.flip_lr {
+		p = p.Flip_LR()
+	}
+	return p
+}
+
+// Invert undoes Apply : The flips go first, then the transpose
+func (o Orientation) Invert(p Patch) Patch {
+	if o.flip_lr {
+		p = p.Flip_LR()
+	}
+	if o.flip_ud {
+		p = p.Flip_UD()
+	}
+	if o.transpose {
+		p = p.Transpose()
+	}
+	return p
+}
+
 type PatchOrientation struct {
 	patch Patch
-	flip_ud, flip_lr bool
+	Orientation
 }
 
+// The canonical (lowest-valued) version of the patch over all of D4, and how to get there
 func (p Patch) BestOrientation() PatchOrientation {  
-	var orientation [4]PatchOrientation
-	
-	// Score the different possibilities
-	orientation[0] = PatchOrientation{ p, false, false}
-	orientation[1] = PatchOrientation{ p.Flip_UD(), true, false }
-	orientation[2] = PatchOrientation{ orientation[0].patch.Flip_LR(), false, true }
-	orientation[3] = PatchOrientation{ orientation[1].patch.Flip_LR(), true, true }
-	
-	best_orientation := orientation[0]
-	for _,this_orientation := range orientation {
-		//fmt.Printf("Patch[%d]=%8d %d %d\n", i, int(this_orientation.patch), this_orientation.flip_ud, this_orientation.flip_lr)
+	best_orientation := PatchOrientation{ p, Orientations_D4[0] }
+	for _,o := range Orientations_D4 {
+		this_orientation := PatchOrientation{ o.Apply(p), o }
+		//fmt.Printf("Patch=%8d %v\n", int(this_orientation.patch), o)
 		if this_orientation.patch < best_orientation.patch {
 			best_orientation = this_orientation
 		}
 	}
-	//fmt.Printf("Best Orientation : (%d,%d) is %8d\n", best_orientation.flip_ud, best_orientation.flip_lr, int(best_orientation.patch)) 
+	//fmt.Printf("Best Orientation : %v is %8d\n", best_orientation.Orientation, int(best_orientation.patch)) 
 	return best_orientation
 }
 
-func (tc *TransitionCollectionList) GetRandomEntry_OrientationCompensated(q Patch) Patch {
+func (tc *TransitionCollectionList) GetRandomEntry_OrientationCompensated(q Patch, random *rand.Rand) Patch {
 	oriented := q.BestOrientation()
 	
 	if pl, ok :=tc.pre[oriented.patch]; ok {
 		// if found, then copy a random one of its starters into the new individual
 		//fmt.Printf("Found known end!\n")
-		p := pl.GetRandomEntry()
-		
-		// Do the same (best) orientation maneuver on p
-		if oriented.flip_ud {
-			p = p.Flip_UD()
-		}
-		if oriented.flip_lr {
-			p = p.Flip_LR()
-		}
+		p := pl.GetRandomEntry(random)
 		
-		return p
+		// The starts are stored in the canonical frame : Bring p back to q's frame
+		return oriented.Invert(p)
 	}
 	//fmt.Printf("Did not find known end!\n")
 	
@@ -196,17 +235,17 @@ func (starts PatchMap) GetRandomEntryUNUSED() Patch {  // Not in use because of
 }
 */
 
-func (pl PatchList) GetRandomEntry_v1002() Patch {
+func (pl PatchList) GetRandomEntry_v1002(random *rand.Rand) Patch {
 	n_starts := len(pl.starts)
-	start_random_index := rand.Intn(n_starts)
+	start_random_index := random.Intn(n_starts)
 	return pl.starts[start_random_index].patch
 }
 // v1016 :: This makes it more likely to pick something near the beginning of the list
-func (pl PatchList) GetRandomEntry_v1016() Patch {
+func (pl PatchList) GetRandomEntry_v1016(random *rand.Rand) Patch {
 	n_starts := len(pl.starts)
-	start_random_index1 := rand.Intn(n_starts)
-	start_random_index2 := rand.Intn(n_starts)
-	if rand.Intn(100)<90 {
+	start_random_index1 := random.Intn(n_starts)
+	start_random_index2 := random.Intn(n_starts)
+	if random.Intn(100)<90 {
 		if start_random_index2<start_random_index1 {
 			start_random_index1=start_random_index2
 		}
@@ -215,8 +254,8 @@ func (pl PatchList) GetRandomEntry_v1016() Patch {
 }
 
 // v1018 :: This picks according to frequency distribution
-func (pl PatchList) GetRandomEntry_v1018() Patch {
-	random_index := rand.Intn(pl.freq_total)
+func (pl PatchList) GetRandomEntry_v1018(random *rand.Rand) Patch {
+	random_index := random.Intn(pl.freq_total)
 
 	patch := Patch(-1)
 	acc:=0
@@ -230,8 +269,8 @@ func (pl PatchList) GetRandomEntry_v1018() Patch {
 	return patch
 }
 
-func (pl PatchList) GetRandomEntry() Patch {
-	return pl.GetRandomEntry_v1016()
+func (pl PatchList) GetRandomEntry(random *rand.Rand) Patch {
+	return pl.GetRandomEntry_v1016(random)
 }
 
 type TransitionCollectionMap struct {
@@ -271,12 +310,7 @@ func (t *TransitionCollectionMap) AddTransitionToMap(start, end *Board_BoolPacke
 			q = oriented.patch
 			
 			// Do the same (best) orientation maneuver on p
-			if oriented.flip_ud {
-				p = p.Flip_UD()
-			}
-			if oriented.flip_lr {
-				p = p.Flip_LR()
-			}
+			p = oriented.Apply(p)
 			
 			if len(t.pre[q])>0 {
 				// If end_rep exists : no need to create map, it's already there
@@ -343,8 +377,9 @@ func (t *TransitionCollectionMap) TrainingCSV_to_stats(f string, step_filter int
 			start := NewBoard_BoolPacked(board_width, board_height)
 			end   := NewBoard_BoolPacked(board_width, board_height)
 			
-			start.LoadArray(record[2:402])
-			end.LoadArray(record[402:802])
+			cells := start.w*start.h
+			start.LoadArray(record[2:2+cells])
+			end.LoadArray(record[2+cells:2+2*cells])
 			
 			existing_map_count := t.AddTransitionToMap(start, end)
 			
@@ -417,7 +452,7 @@ func (t *TransitionCollectionMap) TrainingCSV_to_stats(f string, step_filter int
 	fmt.Printf("Total record  count : %7d\n", record_count) 
 }
 
-func (t *TransitionCollectionMap) TrainingSynthetic_to_stats(steps int, iter_max int) {
+func (t *TransitionCollectionMap) TrainingSynthetic_to_stats(steps int, iter_max int, rule *Rule) {
 	if t.pre == nil {
 		t.pre = make(map[Patch]PatchMap)
 	}
@@ -437,6 +472,7 @@ func (t *TransitionCollectionMap) TrainingSynthetic_to_stats(steps int, iter_max
 			
 			// transition it forwards 5 times
 			l := NewBoardIterator(board_width, board_height)
+			l.rule = rule
 			l.current.CopyFrom(initial)
 			
 			l.Iterate(5)
@@ -453,7 +489,7 @@ func (t *TransitionCollectionMap) TrainingSynthetic_to_stats(steps int, iter_max
 			//fmt.Println(end)
 			
 			// if end is not empty, then we've succeeded
-			if end.CompareTo(board_empty, nil) > 0 {
+			if end.Popcount() > 0 {
 				found = true
 				//fmt.Println("Success!")
 			}
@@ -469,6 +505,15 @@ func (t *TransitionCollectionMap) TrainingSynthetic_to_stats(steps int, iter_max
 
 
 const TransitionCollectionFileStrFmt = "stats/transition-%d.csv"
+const TransitionCollectionRuleFileStrFmt = "stats/transition-%s-%d.csv"
+
+// Conway keeps the original filenames, other rules get their own (e.g. "stats/transition-B36S23-1.csv")
+func TransitionCollectionFile(steps int, rule *Rule) string {
+	if rule.IsConway() {
+		return fmt.Sprintf(TransitionCollectionFileStrFmt, steps)
+	}
+	return fmt.Sprintf(TransitionCollectionRuleFileStrFmt, strings.Replace(rule.String(), "/", "", -1), steps)
+}
 
 type ByFreqDesc []PatchFreq
 func (a ByFreqDesc) Len() int           { return len(a) }
@@ -0,0 +1,167 @@
+// An implementation of Conway's Game of Life.
+// See reverse-gol.go for build/run
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// WalkSAT-style local search : Each end cell is a constraint on the start cells within steps of it (its
+// light cone, i.e. the 3x3 around it for one step).  Pick a wrong end cell from the diff, then flip one of
+// the start cells in its light cone : One that puts nothing else wrong (a free flip) if there is one, otherwise
+// (noise_pct of the time) any of them at random, or else the one that puts fewest right end cells wrong
+// (its break), with most wrong ones put right (its make) as the tie-break.  Cells flipped in the last few flips
+// are left alone (unless flipping them would cut the mismatch), which stops it going round in circles.
+// Meant for quick 1-step problems.
+
+type WalkSettings struct {
+	noise_pct    int // Flips made at random within the light cone (when there's no free flip)
+	tabu         int // Flips before a cell can be flipped back (0 = any time)
+	flips        int // Flips in each try
+	tries        int // Tries, each starting again from the end board
+	report_every int // Flips between progress lines (0 = none)
+}
+
+// What Solver_Walk uses (-type=walk on the run command)
+var walk_settings = WalkSettings{noise_pct: 10, tabu: 5, flips: 20000, tries: 5, report_every: 10000}
+
+type WalkSearch struct {
+	*IncrementalBoard
+	settings WalkSettings
+	random   *rand.Rand
+
+	flips      int
+	flipped    []int            // When each cell was last flipped, by y*w+x
+	candidates []walk_candidate // Scratch space for Flip
+}
+
+type walk_candidate struct {
+	x, y         int
+	made, broken int
+}
+
+func NewWalkSearch(target *Board_BoolPacked, steps int, rule *Rule, settings WalkSettings, random *rand.Rand) *WalkSearch {
+	return &WalkSearch{
+		IncrementalBoard: NewIncrementalBoard(target, steps, rule),
+		settings:         settings,
+		random:           random,
+		flipped:          make([]int, target.w*target.h),
+	}
+}
+
+// Flip makes one flip, chosen as above.  Returns false if there aren't any wrong end cells to work on
+func (w *WalkSearch) Flip() bool {
+	x, y := w.Chase().RandomBitPosition(w.random)
+	if x < 0 || y < 0 {
+		return false
+	}
+	w.flips++
+
+	// Score every start cell in the light cone of (x,y)
+	w.candidates = w.candidates[:0]
+	for dy := -w.steps; dy <= w.steps; dy++ {
+		for dx := -w.steps; dx <= w.steps; dx++ {
+			cx, cy := x+dx, y+dy
+			if w.start.boundary == Boundary_Torus {
+				cx, cy = (cx+w.start.w)%w.start.w, (cy+w.start.h)%w.start.h
+			} else if cx < 0 || cx >= w.start.w || cy < 0 || cy >= w.start.h {
+				continue
+			}
+			w.trial.Set(cx, cy, !w.trial.isSet(cx, cy))
+			c, _ := w.evaluate()
+			made, broken := w.make_break(c)
+			w.revert(c)
+			if last := w.flipped[cy*w.start.w+cx]; last > 0 && w.flips-last <= w.settings.tabu && made <= broken {
+				continue
+			}
+			w.candidates = append(w.candidates, walk_candidate{cx, cy, made, broken})
+		}
+	}
+
+	if len(w.candidates) == 0 { // All tabu
+		return true
+	}
+
+	// Free flips first, then noise, then least break (most make), with ties picked at random
+	chosen, ties := -1, 0
+	for i, a := range w.candidates {
+		if chosen < 0 {
+			chosen, ties = i, 1
+			continue
+		}
+		b := w.candidates[chosen]
+		if a.broken < b.broken || (a.broken == b.broken && a.made > b.made) {
+			chosen, ties = i, 1
+		} else if a.broken == b.broken && a.made == b.made {
+			ties++
+			if w.random.Intn(ties) == 0 {
+				chosen = i
+			}
+		}
+	}
+	if w.candidates[chosen].broken > 0 && w.random.Intn(100) < w.settings.noise_pct {
+		chosen = w.random.Intn(len(w.candidates))
+	}
+
+	cx, cy := w.candidates[chosen].x, w.candidates[chosen].y
+	w.flipped[cy*w.start.w+cx] = w.flips
+	w.trial.Set(cx, cy, !w.trial.isSet(cx, cy))
+	if c, ok := w.evaluate(); ok {
+		w.commit(c)
+	}
+	return true
+}
+
+// Run flips (with restarts from the end board) until the tries are used up, a board with only the floor of
+// mismatches is found, or the deadline (if not zero) passes.  Returns the best start board found
+func (w *WalkSearch) Run(deadline time.Time) *Board_BoolPacked {
+	t0 := time.Now()
+	best := NewBoard_BoolPacked(w.start.w, w.start.h)
+	best.CopyFrom(w.start)
+	best_mismatch := w.mismatch
+
+	for try := 0; try < w.settings.tries; try++ {
+		if try > 0 {
+			w.Reset(w.target)
+		}
+		for i := 0; i < w.settings.flips; i++ {
+			if !w.Flip() {
+				break
+			}
+			if w.mismatch < best_mismatch {
+				best.CopyFrom(w.start)
+				best_mismatch = w.mismatch
+			}
+			if w.settings.report_every > 0 && w.flips%w.settings.report_every == 0 {
+				fmt.Printf("%7d flips (try %d, %5.1fs) : Mismatch vs end = %3d (best %3d)\n",
+					w.flips, try, time.Since(t0).Seconds(), w.mismatch, best_mismatch)
+			}
+			if best_mismatch <= w.floor {
+				return best
+			}
+			if w.flips%256 == 0 && !deadline.IsZero() && time.Now().After(deadline) {
+				return best
+			}
+		}
+	}
+	return best
+}
+
+type Solver_Walk struct{}
+
+func (Solver_Walk) Solve(problem LifeProblem, lps *LifeProblemSet, budget time.Duration, random *rand.Rand) *IndividualResult {
+	w := NewWalkSearch(problem.end, problem.steps, problem.Rule(), walk_settings, random)
+	if orphans := problem_orphans(problem, w.known); orphans.orphans > 0 {
+		w.unfixable, w.floor = orphans.unfixable, orphans.min_errors
+	}
+
+	mismatch_from_true_end_initial := w.mismatch
+	start := w.Run(budget_deadline(budget))
+
+	result := finish_result(problem, lps, start, mismatch_from_true_end_initial)
+	result.iter = w.flips
+	return result
+}